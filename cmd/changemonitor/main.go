@@ -49,6 +49,7 @@ func main() {
 	storageService := storage.InitStorage(StorageDirectory)
 
 	monitorService = monitor.NewMonitorService(config.Monitors, storageService, notifierService)
+	monitorService.SetDefaults(config.Defaults)
 	if err := monitorService.SetupChrome(ChromePath, ChromeWs); err != nil {
 		log.Fatal(err)
 	}
@@ -9,8 +9,17 @@ import (
 )
 
 type Config struct {
-	Monitors  monitor.Monitors `json:"monitors"`
-	Notifiers NotifiersConfig  `json:"notifiers"`
+	Monitors  monitor.Monitors  `json:"monitors"`
+	Defaults  *monitor.Defaults `json:"defaults,omitempty"`
+	Notifiers NotifiersConfig   `json:"notifiers"`
+	Feed      *FeedConfig       `json:"feed,omitempty"`
+}
+
+// FeedConfig configures the Atom feed of detected changes.
+type FeedConfig struct {
+	// Token, when set, must be passed as the token query parameter or as a
+	// bearer token to read the feed.
+	Token string `json:"token,omitempty"`
 }
 
 // NotifiersConfig holds the configuration for each supported notifier type.
@@ -29,7 +38,7 @@ type LoggerConfig struct {
 	Enabled bool `json:"enabled"`
 }
 
-// Load reads and parses a JSON config file.
+// Load reads, parses and validates a JSON config file.
 func Load(filename string) (*Config, error) {
 	b, err := os.ReadFile(filename)
 	if err != nil {
@@ -39,6 +48,9 @@ func Load(filename string) (*Config, error) {
 	if err := json.Unmarshal(b, &cfg); err != nil {
 		return nil, err
 	}
+	if err := cfg.Monitors.Validate(); err != nil {
+		return nil, err
+	}
 	return &cfg, nil
 }
 
@@ -1,6 +1,119 @@
+export interface RegexOptions {
+  patterns?: string[]
+  flags?: string
+  template?: string
+  separator?: string
+  raw?: boolean
+}
+
+export interface Step {
+  type: string
+  paths?: string[]
+  name?: string
+  regex?: RegexOptions
+  pattern?: string
+  replacement?: string
+}
+
+export interface StepResult {
+  type: string
+  items: string[] | null
+  error?: string
+}
+
+export interface IgnoreRules {
+  selectors?: string[]
+  patterns?: string[]
+  lines?: string[]
+}
+
+export interface Normalization {
+  collapseWhitespace?: boolean
+  dropBlankLines?: boolean
+  sortLines?: boolean
+  caseFold?: boolean
+  nfkc?: boolean
+  stripDigits?: boolean
+  stripNumbers?: boolean
+}
+
+export interface Threshold {
+  linesPercent?: number
+  charsPercent?: number
+  minLines?: number
+  maxSimilarity?: number
+  keepBaseline?: boolean
+}
+
+export interface ChangeMagnitude {
+  addedLines: number
+  removedLines: number
+  linesPercent: number
+  charsPercent: number
+  similarity: number
+}
+
+export interface Condition {
+  all?: Condition[]
+  any?: Condition[]
+  contains?: string
+  regex?: string
+  ignoreCase?: boolean
+  when?: 'present' | 'absent' | 'appeared' | 'disappeared'
+}
+
+export interface NumericSettings {
+  above?: number
+  below?: number
+  delta?: number
+  deltaPercent?: number
+  crosses?: number[]
+  historySize?: number
+}
+
+export interface Sample {
+  time: string
+  value: number
+}
+
+export interface ItemSettings {
+  key?: string
+  title?: string
+  link?: string
+  reportRemoved?: boolean
+}
+
+export interface SitemapChildren {
+  pattern: string
+  interval?: number
+  selector?: Selector
+  max?: number
+}
+
+export interface SitemapSettings {
+  maxSitemaps?: number
+  ignoreUpdated?: boolean
+  children?: SitemapChildren
+}
+
+export interface Item {
+  key: string
+  title?: string
+  link?: string
+  summary?: string
+}
+
+export interface TextSegment {
+  text: string
+  ignored?: boolean
+}
+
 export interface Selector {
   type: string
   paths: string[]
+  regex?: RegexOptions
+  perMatch?: boolean
+  steps?: Step[]
 }
 
 export interface Filters {
@@ -15,8 +128,86 @@ export interface ProductDetection {
   maxPrice?: number
 }
 
+export interface BlockRules {
+  resourceTypes?: string[]
+  urlPatterns?: string[]
+}
+
+export interface Cookie {
+  name: string
+  value: string
+  domain: string
+  path?: string
+  expires?: string
+  secure?: boolean
+  httpOnly?: boolean
+  hostOnly?: boolean
+}
+
+export interface CookieSettings {
+  persist?: boolean
+  seed?: Cookie[]
+}
+
+export interface Auth {
+  type: 'basic' | 'bearer' | 'form' | 'chrome'
+  username?: string
+  password?: string
+  token?: string
+  loginUrl?: string
+  fields?: Record<string, string>
+  script?: string
+  successSelector?: string
+  loggedOutSelector?: string
+  sessionMaxAge?: number
+}
+
+export interface RequestBody {
+  type?: 'raw' | 'json' | 'form'
+  content?: string
+  json?: unknown
+  form?: Record<string, string>
+  contentType?: string
+}
+
+export interface ProxySettings {
+  urls: string[]
+}
+
+export interface ResponseTracking {
+  statusCode?: boolean
+  finalUrl?: boolean
+  headers?: string[]
+}
+
+export interface TLSSettings {
+  caFile?: string
+  certFile?: string
+  keyFile?: string
+  insecureSkipVerify?: boolean
+}
+
+export interface CertificateSettings {
+  warnDays?: number
+}
+
+export interface Politeness {
+  respectRobots?: boolean
+  userAgent?: string
+  minDelay?: number
+  maxConcurrent?: number
+}
+
+export interface Defaults {
+  block?: BlockRules
+  proxy?: ProxySettings
+  maxBodySize?: number
+  politeness?: Politeness
+}
+
 export interface Monitor {
   name: string
+  type?: string
   url: string
   httpHeaders?: Record<string, string[]>
   useChrome: boolean
@@ -25,6 +216,25 @@ export interface Monitor {
   filters?: Filters
   ignoreEmpty?: boolean
   productDetection?: ProductDetection
+  block?: BlockRules
+  cookies?: CookieSettings
+  auth?: Auth
+  method?: string
+  body?: RequestBody
+  proxy?: ProxySettings
+  acceptStatus?: number[]
+  trackResponse?: ResponseTracking
+  maxBodySize?: number
+  tls?: TLSSettings
+  certificate?: CertificateSettings
+  ignore?: IgnoreRules
+  normalize?: Normalization
+  threshold?: Threshold
+  trigger?: 'any' | 'added' | 'removed'
+  conditions?: Condition
+  numeric?: NumericSettings
+  items?: ItemSettings
+  sitemap?: SitemapSettings
 }
 
 export interface PushoverConfig {
@@ -38,7 +248,24 @@ export interface Notifiers {
 
 export interface Config {
   monitors: Monitor[]
+  defaults?: Defaults
   notifiers: Notifiers
+  feed?: FeedConfig
+}
+
+export interface FeedConfig {
+  token?: string
+}
+
+export interface MonitorStatus {
+  name: string
+  state: 'idle' | 'checking' | 'waiting' | 'error'
+  message?: string
+  lastCheck?: string
+  lastChange?: string
+  lastError?: string
+  magnitude?: ChangeMagnitude
+  contentType?: string
 }
 
 export interface Notification {
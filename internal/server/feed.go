@@ -0,0 +1,141 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Ordspilleren/ChangeMonitor/monitor"
+)
+
+// maxFeedEntries caps the entries in the change feed.
+const maxFeedEntries = 100
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Content atomContent `xml:"content"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// handleFeed serves the recent changes of every monitor, or of the monitor
+// named by the monitor query parameter, as an Atom feed.
+func (s *Server) handleFeed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.feedAuthorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="feed"`)
+		http.Error(w, "invalid feed token", http.StatusUnauthorized)
+		return
+	}
+
+	name := r.URL.Query().Get("monitor")
+	changes, err := s.monitorService.Changes(name)
+	if err != nil {
+		if errors.Is(err, monitor.ErrMonitorNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(changes) > maxFeedEntries {
+		changes = changes[:maxFeedEntries]
+	}
+
+	feed := atomFeed{
+		ID:      "urn:changemonitor:changes",
+		Title:   "ChangeMonitor changes",
+		Updated: time.Now().UTC().Format(time.RFC3339),
+		Links:   []atomLink{{Href: requestURL(r), Rel: "self"}},
+		Author:  atomAuthor{Name: "ChangeMonitor"},
+	}
+	if name != "" {
+		feed.ID += ":" + url.PathEscape(name)
+		feed.Title = "ChangeMonitor: " + name
+	}
+	if len(changes) > 0 {
+		feed.Updated = changes[0].Time.UTC().Format(time.RFC3339)
+	}
+	for _, c := range changes {
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:      "urn:changemonitor:change:" + c.ID,
+			Title:   c.Subject,
+			Updated: c.Time.UTC().Format(time.RFC3339),
+			Link:    atomLink{Href: c.URL, Rel: "alternate"},
+			Content: atomContent{Type: "html", Body: changeHTML(c)},
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	enc.Encode(feed)
+}
+
+// feedAuthorized reports whether r carries the configured feed token, if
+// any.
+func (s *Server) feedAuthorized(r *http.Request) bool {
+	feed := s.currentConfig().Feed
+	if feed == nil || feed.Token == "" {
+		return true
+	}
+	token := r.URL.Query().Get("token")
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		token = bearer
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(feed.Token)) == 1
+}
+
+// changeHTML renders a change as the HTML content of a feed entry.
+func changeHTML(c monitor.Change) string {
+	body := c.Diff
+	if body == "" {
+		body = c.Message
+	}
+	return fmt.Sprintf(`<p><a href="%s">%s</a></p><pre>%s</pre>`,
+		html.EscapeString(c.URL), html.EscapeString(c.URL), html.EscapeString(body))
+}
+
+// requestURL reconstructs the absolute URL of r.
+func requestURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + r.URL.RequestURI()
+}
@@ -2,16 +2,21 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
 	"io/fs"
 	"log"
 	"net/http"
 	"os"
+	"sync"
 
 	appcfg "github.com/Ordspilleren/ChangeMonitor/config"
 	"github.com/Ordspilleren/ChangeMonitor/monitor"
 )
 
 type Server struct {
+	// mu guards config, which postConfig replaces while other handlers read
+	// it.
+	mu             sync.RWMutex
 	config         *appcfg.Config
 	configFile     string
 	mux            *http.ServeMux
@@ -27,6 +32,10 @@ func NewServer(config *appcfg.Config, configFile string, staticFS fs.FS, monitor
 	}
 	s.mux.HandleFunc("/api/config", s.handleConfig)
 	s.mux.HandleFunc("/api/preview", s.handlePreview)
+	s.mux.HandleFunc("/api/cookies", s.handleCookies)
+	s.mux.HandleFunc("/api/status", s.handleStatus)
+	s.mux.HandleFunc("/api/series", s.handleSeries)
+	s.mux.HandleFunc("/feed.atom", s.handleFeed)
 	s.mux.Handle("/", http.FileServer(http.FS(staticFS)))
 	return s
 }
@@ -60,6 +69,66 @@ func (s *Server) handlePreview(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(result)
 }
 
+func (s *Server) handleCookies(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := r.URL.Query().Get("monitor")
+	if name == "" {
+		http.Error(w, "missing monitor parameter", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.monitorService.ClearCookies(name); err != nil {
+		if errors.Is(err, monitor.ErrMonitorNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.monitorService.Statuses())
+}
+
+func (s *Server) handleSeries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := r.URL.Query().Get("monitor")
+	if name == "" {
+		http.Error(w, "missing monitor parameter", http.StatusBadRequest)
+		return
+	}
+
+	series, err := s.monitorService.Series(name)
+	if err != nil {
+		if errors.Is(err, monitor.ErrMonitorNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(series)
+}
+
 func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
@@ -71,8 +140,22 @@ func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// currentConfig returns the configuration in effect.
+func (s *Server) currentConfig() *appcfg.Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.config
+}
+
+// getConfig serves the configuration without the feed token, which would
+// otherwise be readable by anyone who can reach the feed. The feed setting
+// is kept, empty, to show that a token is set.
 func (s *Server) getConfig(w http.ResponseWriter) {
-	data, err := s.config.JSON()
+	config := *s.currentConfig()
+	if config.Feed != nil {
+		config.Feed = &appcfg.FeedConfig{}
+	}
+	data, err := config.JSON()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -87,19 +170,17 @@ func (s *Server) postConfig(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-
-	data, err := newConfig.JSON()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := newConfig.Monitors.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	if err := os.WriteFile(s.configFile, data, 0644); err != nil {
+
+	if err := s.saveConfig(&newConfig); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	s.config = &newConfig
-
+	s.monitorService.SetDefaults(newConfig.Defaults)
 	if err := s.monitorService.Reload(newConfig.Monitors); err != nil {
 		log.Printf("server: config reload: %v", err)
 		http.Error(w, "config saved but monitors failed to reload: "+err.Error(), http.StatusInternalServerError)
@@ -108,3 +189,23 @@ func (s *Server) postConfig(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// saveConfig writes config to the config file and puts it in effect. A feed
+// setting without a token, as served by getConfig, keeps the current token;
+// leaving the feed out removes it.
+func (s *Server) saveConfig(config *appcfg.Config) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if config.Feed != nil && config.Feed.Token == "" {
+		config.Feed = s.config.Feed
+	}
+	data, err := config.JSON()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.configFile, data, 0644); err != nil {
+		return err
+	}
+	s.config = config
+	return nil
+}
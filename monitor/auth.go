@@ -0,0 +1,267 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+)
+
+// sessionKind is the storage suffix recording when a monitor last signed in.
+const sessionKind = "session"
+
+// chromeLoginTimeout bounds how long a Chrome login script may take.
+const chromeLoginTimeout = time.Minute
+
+var errStillLoggedOut = errors.New("auth: still logged out after signing in")
+
+// Auth configures how a monitor signs in to the page it watches.
+//
+// The "basic" and "bearer" types send an Authorization header with every
+// request. The "form" type posts Fields to LoginURL, and the "chrome" type
+// opens LoginURL in Chrome and runs Script to sign in. The session created by
+// a form or Chrome login lives in the monitor's cookie jar and is reused until
+// it reaches SessionMaxAge or a check finds the monitor logged out.
+type Auth struct {
+	Type     string `json:"type"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Token    string `json:"token,omitempty"`
+
+	LoginURL string `json:"loginUrl,omitempty"`
+	// Fields are posted by a form login, on top of any hidden inputs found
+	// on the page at LoginURL (such as CSRF tokens).
+	Fields map[string]string `json:"fields,omitempty"`
+	// Script is JavaScript evaluated on LoginURL by a Chrome login. Once it
+	// has run, the login waits for SuccessSelector to become visible.
+	Script          string `json:"script,omitempty"`
+	SuccessSelector string `json:"successSelector,omitempty"`
+
+	// LoggedOutSelector marks a page served to signed-out visitors. Being
+	// redirected to LoginURL or receiving a 401 or 403 status is always
+	// treated as being logged out.
+	LoggedOutSelector string `json:"loggedOutSelector,omitempty"`
+	// SessionMaxAge, in minutes, forces a new login once the session is this
+	// old. Zero keeps a session until the site ends it.
+	SessionMaxAge time.Duration `json:"sessionMaxAge,omitempty"`
+}
+
+// authenticator runs the login flow described by an Auth.
+type authenticator struct {
+	auth *Auth
+	// http sends form logins, with politeness applied like any other
+	// request to the host.
+	http   MonitorClient
+	chrome *ChromeClient
+}
+
+func newAuthenticator(auth *Auth, ms *MonitorService) *authenticator {
+	if auth == nil {
+		return nil
+	}
+	_, polite := ms.settings()
+	return &authenticator{auth: auth, http: polite.wrap(ms.httpClient), chrome: ms.chromeClient}
+}
+
+// usesSession reports whether the login produces a cookie session.
+func (a *authenticator) usesSession() bool {
+	return a.auth.Type == "form" || a.auth.Type == "chrome"
+}
+
+// fetch retrieves req through client, signing in first when haveSession is
+// false and again when the response shows the session has ended. It reports
+// whether a login took place so the caller can record the new session.
+func (a *authenticator) fetch(client MonitorClient, req Request, haveSession bool) (*Response, bool, error) {
+	a.apply(&req)
+	if !a.usesSession() {
+		resp, err := client.GetContent(req)
+		return resp, false, err
+	}
+
+	loggedIn := false
+	if !haveSession {
+		if err := a.login(req); err != nil {
+			return nil, false, err
+		}
+		loggedIn = true
+	}
+	for {
+		resp, err := client.GetContent(req)
+		out, err := a.loggedOut(resp, err)
+		if err != nil {
+			return nil, loggedIn, err
+		}
+		if !out {
+			return resp, loggedIn, nil
+		}
+		if loggedIn {
+			return nil, true, errStillLoggedOut
+		}
+		log.Printf("auth: session for %s has ended, signing in again", req.URL)
+		if err := a.login(req); err != nil {
+			return nil, false, err
+		}
+		loggedIn = true
+	}
+}
+
+// apply adds the Authorization header for basic and bearer auth.
+func (a *authenticator) apply(req *Request) {
+	var value string
+	switch a.auth.Type {
+	case "basic":
+		creds := a.auth.Username + ":" + a.auth.Password
+		value = "Basic " + base64.StdEncoding.EncodeToString([]byte(creds))
+	case "bearer":
+		value = "Bearer " + a.auth.Token
+	default:
+		return
+	}
+	headers := cloneHeader(req.Headers)
+	headers.Set("Authorization", value)
+	req.Headers = headers
+}
+
+func (a *authenticator) login(req Request) error {
+	log.Printf("auth: signing in at %s", a.auth.LoginURL)
+	switch a.auth.Type {
+	case "form":
+		return a.formLogin(req)
+	case "chrome":
+		if a.chrome == nil {
+			return fmt.Errorf("auth: chrome client not initialised")
+		}
+		return a.chrome.runLogin(req, a.auth.LoginURL, a.auth.Script, a.auth.SuccessSelector)
+	default:
+		return fmt.Errorf("auth: unknown type %q", a.auth.Type)
+	}
+}
+
+// formLogin loads the login page to pick up its cookies and hidden inputs,
+// then posts the form with the configured fields. Both requests share the
+// cookie jar, proxy and limits of req.
+func (a *authenticator) formLogin(req Request) error {
+	login := req
+	login.URL = a.auth.LoginURL
+	login.Method, login.Body, login.ContentType = http.MethodGet, nil, ""
+	login.Validators = nil
+	login.AcceptStatus, login.AcceptAnyStatus = nil, true
+
+	form := url.Values{}
+	if resp, err := a.http.GetContent(login); err == nil {
+		body := io.LimitReader(resp.Body, firstPositive(req.MaxBodySize, defaultMaxBodySize))
+		if doc, err := goquery.NewDocumentFromReader(body); err == nil {
+			doc.Find(`input[type="hidden"][name]`).Each(func(_ int, s *goquery.Selection) {
+				form.Set(s.AttrOr("name", ""), s.AttrOr("value", ""))
+			})
+		}
+		resp.Body.Close()
+	}
+	for k, v := range a.auth.Fields {
+		form.Set(k, v)
+	}
+
+	login.Method = http.MethodPost
+	login.Body = []byte(form.Encode())
+	login.ContentType = "application/x-www-form-urlencoded"
+	resp, err := a.http.GetContent(login)
+	if err != nil {
+		return fmt.Errorf("auth: post login form: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("auth: post login form: %w", &StatusError{Code: resp.StatusCode})
+	}
+	return nil
+}
+
+// loggedOut inspects the outcome of a fetch for signs that the session has
+// ended. When the body has to be read to tell, it is buffered and put back.
+// A logged-out response is closed before returning.
+func (a *authenticator) loggedOut(resp *Response, err error) (bool, error) {
+	if err != nil {
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && (statusErr.Code == http.StatusUnauthorized || statusErr.Code == http.StatusForbidden) {
+			return true, nil
+		}
+		return false, err
+	}
+	// Accepted statuses do not fail the fetch, so they are checked here too.
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		resp.Body.Close()
+		return true, nil
+	}
+	if a.auth.LoginURL != "" && stripQuery(resp.URL) == stripQuery(a.auth.LoginURL) {
+		resp.Body.Close()
+		return true, nil
+	}
+	if a.auth.LoggedOutSelector == "" {
+		return false, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return false, fmt.Errorf("auth: read body: %w", err)
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("auth: goquery: %w", err)
+	}
+	if doc.Find(a.auth.LoggedOutSelector).Length() > 0 {
+		return true, nil
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return false, nil
+}
+
+func stripQuery(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
+}
+
+// runLogin opens loginURL in a fresh tab prepared for req, evaluates script
+// and waits for the page to show waitSelector. The resulting cookies are
+// copied into req's jar.
+func (c *ChromeClient) runLogin(req Request, loginURL, script, waitSelector string) error {
+	ctx, cancel := c.newTab(req.Proxy)
+	defer cancel()
+	ctx, cancelTimeout := context.WithTimeout(ctx, chromeLoginTimeout)
+	defer cancelTimeout()
+
+	var finalURL string
+	actions := chromeSetup(ctx, req)
+	actions = append(actions,
+		chromedp.Navigate(loginURL),
+		chromedp.Evaluate(script, nil, func(p *runtime.EvaluateParams) *runtime.EvaluateParams {
+			return p.WithAwaitPromise(true)
+		}),
+	)
+	if waitSelector != "" {
+		actions = append(actions, chromedp.WaitVisible(waitSelector))
+	} else {
+		actions = append(actions, chromedp.Sleep(3*time.Second))
+	}
+	actions = append(actions, chromedp.Location(&finalURL))
+	actions = append(actions, chromeSyncCookies(req, &finalURL)...)
+
+	if err := chromedp.Run(ctx, actions); err != nil {
+		return fmt.Errorf("auth: chrome login: %w", err)
+	}
+	return nil
+}
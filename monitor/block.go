@@ -0,0 +1,81 @@
+package monitor
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+)
+
+// BlockRules lists resources that ChromeClient refuses to load while rendering
+// a page. Blocking ads, fonts, images and trackers makes checks faster and
+// stops third-party widgets from showing up as changes. Plain HTTP fetches
+// ignore these rules since they never load sub-resources.
+type BlockRules struct {
+	// ResourceTypes holds DevTools resource types such as "Image", "Font",
+	// "Stylesheet", "Media" or "Script". Matching is case-insensitive.
+	ResourceTypes []string `json:"resourceTypes,omitempty"`
+	// URLPatterns holds wildcard patterns matched against the full request
+	// URL, where "*" matches any run of characters and "?" a single one.
+	URLPatterns []string `json:"urlPatterns,omitempty"`
+}
+
+// blocker is the compiled form of BlockRules used during a single fetch.
+type blocker struct {
+	types    map[string]struct{}
+	patterns []*regexp.Regexp
+	// mainFrame is the frame of the first document requested, which is the
+	// page itself.
+	mainFrame cdp.FrameID
+}
+
+// compile returns nil when r is nil or blocks nothing.
+func (r *BlockRules) compile() *blocker {
+	if r == nil || (len(r.ResourceTypes) == 0 && len(r.URLPatterns) == 0) {
+		return nil
+	}
+	b := &blocker{types: make(map[string]struct{}, len(r.ResourceTypes))}
+	for _, t := range r.ResourceTypes {
+		b.types[strings.ToLower(t)] = struct{}{}
+	}
+	for _, p := range r.URLPatterns {
+		b.patterns = append(b.patterns, wildcardToRegexp(p))
+	}
+	return b
+}
+
+// blocks reports whether the paused request should be failed. Documents
+// loaded into the main frame, the page and any redirects it goes through,
+// are never blocked, whatever the rules say. Paused requests are reported
+// one at a time, so b is not locked.
+func (b *blocker) blocks(ev *fetch.EventRequestPaused) bool {
+	if ev.Request == nil {
+		return false
+	}
+	if ev.ResourceType == network.ResourceTypeDocument {
+		if b.mainFrame == "" {
+			b.mainFrame = ev.FrameID
+		}
+		if ev.FrameID == b.mainFrame {
+			return false
+		}
+	}
+	if _, ok := b.types[strings.ToLower(string(ev.ResourceType))]; ok {
+		return true
+	}
+	for _, re := range b.patterns {
+		if re.MatchString(ev.Request.URL) {
+			return true
+		}
+	}
+	return false
+}
+
+func wildcardToRegexp(pattern string) *regexp.Regexp {
+	quoted := regexp.QuoteMeta(pattern)
+	quoted = strings.ReplaceAll(quoted, `\*`, ".*")
+	quoted = strings.ReplaceAll(quoted, `\?`, ".")
+	return regexp.MustCompile("^" + quoted + "$")
+}
@@ -0,0 +1,159 @@
+package monitor
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"golang.org/x/net/html/charset"
+)
+
+// defaultMaxBodySize caps response bodies when neither the monitor nor the
+// defaults set a limit.
+const defaultMaxBodySize = 10 << 20
+
+// acceptEncoding is sent unless a monitor sets its own Accept-Encoding.
+const acceptEncoding = "gzip, deflate, br"
+
+// ErrBodyTooLarge is returned when a response exceeds the configured maximum
+// body size.
+var ErrBodyTooLarge = errors.New("response body too large")
+
+// decodeBody wraps resp.Body so that it yields at most maxSize bytes of
+// decompressed, UTF-8 encoded content.
+func decodeBody(resp *http.Response, maxSize int64) (io.ReadCloser, error) {
+	var r io.Reader = resp.Body
+	encodings := strings.Split(resp.Header.Get("Content-Encoding"), ",")
+	// Encodings are listed in the order they were applied, so undo them
+	// from last to first.
+	for i := len(encodings) - 1; i >= 0; i-- {
+		var err error
+		if r, err = decompress(r, strings.TrimSpace(encodings[i])); err != nil {
+			return nil, err
+		}
+	}
+	r = &limitedReader{r: r, remaining: maxSize}
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		// Without a header the body is sniffed, and text is decoded by its
+		// byte order mark or meta charset, falling back to Windows-1252 when
+		// it is not valid UTF-8.
+		br := bufio.NewReader(r)
+		peek, _ := br.Peek(1024)
+		r = br
+		if !needsCharsetDecoding(http.DetectContentType(peek)) {
+			return readCloser{Reader: r, Closer: resp.Body}, nil
+		}
+	} else if !needsCharsetDecoding(contentType) {
+		return readCloser{Reader: r, Closer: resp.Body}, nil
+	}
+	r, err := charset.NewReader(r, contentType)
+	if err != nil {
+		return nil, fmt.Errorf("charset: %w", err)
+	}
+	return readCloser{Reader: skipBOM(r), Closer: resp.Body}, nil
+}
+
+// skipBOM drops the byte order mark that decoded text may still start with.
+func skipBOM(r io.Reader) io.Reader {
+	br := bufio.NewReader(r)
+	if b, _ := br.Peek(3); string(b) == "\ufeff" {
+		br.Discard(3)
+	}
+	return br
+}
+
+func decompress(r io.Reader, encoding string) (io.Reader, error) {
+	switch strings.ToLower(encoding) {
+	case "", "identity":
+		return r, nil
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("gzip: %w", err)
+		}
+		return gz, nil
+	case "br":
+		return brotli.NewReader(r), nil
+	case "deflate":
+		// "deflate" should be zlib-wrapped, but some servers send a raw
+		// deflate stream. A zlib header always has 0x?8 as its first byte
+		// and a checksum making the first two bytes divisible by 31.
+		br := bufio.NewReader(r)
+		header, err := br.Peek(2)
+		if err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+			zr, err := zlib.NewReader(br)
+			if err != nil {
+				return nil, fmt.Errorf("deflate: %w", err)
+			}
+			return zr, nil
+		}
+		return flate.NewReader(br), nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+}
+
+// needsCharsetDecoding reports whether a body of contentType is text that
+// may be in a legacy encoding. HTML can declare its charset in the document;
+// other text is decoded as the header says, or detected. Bodies of unknown
+// type may be binary and are left alone, and so is XML, whose parsers
+// decode it according to its encoding declaration.
+func needsCharsetDecoding(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch mediaType {
+	case "text/html", "application/xhtml+xml":
+		return true
+	case "text/xml":
+		return false
+	default:
+		return strings.HasPrefix(mediaType, "text/")
+	}
+}
+
+// limitedReader fails with ErrBodyTooLarge instead of silently truncating,
+// so that a cut-off page is never mistaken for a change.
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		// Probe for one more byte to tell an exact fit from an overflow,
+		// reading again when the underlying reader returns nothing yet.
+		var probe [1]byte
+		for {
+			n, err := l.r.Read(probe[:])
+			if n > 0 {
+				return 0, ErrBodyTooLarge
+			}
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	return n, err
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,175 @@
+package monitor
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+// response returns an http.Response with the given body and headers, given
+// as name and value pairs.
+func response(body []byte, header ...string) *http.Response {
+	h := make(http.Header)
+	for i := 0; i+1 < len(header); i += 2 {
+		h.Set(header[i], header[i+1])
+	}
+	return &http.Response{Header: h, Body: io.NopCloser(bytes.NewReader(body))}
+}
+
+func compressed(t *testing.T, newWriter func(io.Writer) io.WriteCloser, data string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := newWriter(&buf)
+	if _, err := io.WriteString(w, data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeBodyEncodings(t *testing.T) {
+	const page = "<p>Hello</p>"
+	gz := func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }
+	zl := func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }
+	raw := func(w io.Writer) io.WriteCloser { fw, _ := flate.NewWriter(w, flate.DefaultCompression); return fw }
+	br := func(w io.Writer) io.WriteCloser { return brotli.NewWriter(w) }
+
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+	}{
+		{"identity", "", []byte(page)},
+		{"gzip", "gzip", compressed(t, gz, page)},
+		{"x-gzip", "x-gzip", compressed(t, gz, page)},
+		{"zlib deflate", "deflate", compressed(t, zl, page)},
+		{"raw deflate", "deflate", compressed(t, raw, page)},
+		{"brotli", "br", compressed(t, br, page)},
+		{"gzip then brotli", "gzip, br", compressed(t, br, string(compressed(t, gz, page)))},
+	}
+	for _, tt := range tests {
+		body, err := decodeBody(response(tt.body, "Content-Encoding", tt.encoding, "Content-Type", "text/html"), 1<<20)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		got, err := io.ReadAll(body)
+		if err != nil || string(got) != page {
+			t.Errorf("%s: body = %q, %v, want %q", tt.name, got, err, page)
+		}
+	}
+
+	if _, err := decodeBody(response(nil, "Content-Encoding", "compress"), 1<<20); err == nil {
+		t.Error("unsupported encoding: got no error")
+	}
+}
+
+func TestDecodeBodyCharsets(t *testing.T) {
+	latin1 := []byte("Cr\xe8me br\xfbl\xe9e")
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+		want        string
+	}{
+		{"header charset", "text/html; charset=iso-8859-1", latin1, "Crème brûlée"},
+		{"meta charset", "text/html", append([]byte(`<meta charset="iso-8859-1">`), latin1...), `<meta charset="iso-8859-1">Crème brûlée`},
+		{"utf-16 byte order mark", "text/plain", []byte("\xff\xfeC\x00r\x00\xe8\x00"), "Crè"},
+		{"utf-8 byte order mark", "text/html", []byte("\xef\xbb\xbf<p>Crème</p>"), "<p>Crème</p>"},
+		{"utf-8 without charset", "text/html", []byte("Crème"), "Crème"},
+		{"plain text charset", "text/plain; charset=windows-1252", []byte("\x80 5"), "€ 5"},
+		{"no header latin-1", "", append([]byte("<html><body>"), latin1...), "<html><body>Crème brûlée"},
+		{"no header meta charset", "", []byte(`<html><meta charset="iso-8859-1">` + "\xe6\xf8\xe5"), `<html><meta charset="iso-8859-1">æøå`},
+		{"no header utf-8", "", []byte("<p>Blåbær</p>"), "<p>Blåbær</p>"},
+		{"no header binary", "", []byte("\x1f\x8b\x08\x00\xe8\xff"), "\x1f\x8b\x08\x00\xe8\xff"},
+		{"no header xml", "", []byte(`<?xml version="1.0" encoding="iso-8859-1"?><a>` + "\xe8</a>"), `<?xml version="1.0" encoding="iso-8859-1"?><a>` + "\xe8</a>"},
+		{"xml", "application/xml", latin1, string(latin1)},
+		{"text/xml", "text/xml; charset=iso-8859-1", latin1, string(latin1)},
+		{"binary", "application/octet-stream", latin1, string(latin1)},
+		{"pdf", "application/pdf", latin1, string(latin1)},
+	}
+	for _, tt := range tests {
+		body, err := decodeBody(response(tt.body, "Content-Type", tt.contentType), 1<<20)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		got, err := io.ReadAll(body)
+		if err != nil || string(got) != tt.want {
+			t.Errorf("%s: body = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+// trickleReader returns one byte per call, with an empty read in between.
+type trickleReader struct {
+	data  []byte
+	empty bool
+}
+
+func (r *trickleReader) Read(p []byte) (int, error) {
+	if r.empty = !r.empty; r.empty {
+		return 0, nil
+	}
+	if len(r.data) == 0 {
+		return 0, io.EOF
+	}
+	p[0], r.data = r.data[0], r.data[1:]
+	return 1, nil
+}
+
+func TestLimitedReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		r       io.Reader
+		limit   int64
+		tooLong bool
+	}{
+		{"under", strings.NewReader("abc"), 4, false},
+		{"exact fit", strings.NewReader("abcd"), 4, false},
+		{"over", strings.NewReader("abcde"), 4, true},
+		{"slow exact fit", &trickleReader{data: []byte("abcd")}, 4, false},
+		{"slow over", &trickleReader{data: []byte("abcde")}, 4, true},
+	}
+	for _, tt := range tests {
+		_, err := io.ReadAll(&limitedReader{r: tt.r, remaining: tt.limit})
+		if got := errors.Is(err, ErrBodyTooLarge); got != tt.tooLong || !got && err != nil {
+			t.Errorf("%s: err = %v, want too large = %t", tt.name, err, tt.tooLong)
+		}
+	}
+}
+
+func TestNeedsCharsetDecoding(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        bool
+	}{
+		{"text/html", true},
+		{"text/html; charset=utf-8", true},
+		{"application/xhtml+xml", true},
+		{"text/plain", true},
+		{"text/csv; charset=iso-8859-1", true},
+		{"text/xml", false},
+		{"application/xml", false},
+		{"application/json", false},
+		{"application/pdf", false},
+		{"image/png", false},
+		{"", false},
+		{"not a type;;", false},
+	}
+	for _, tt := range tests {
+		if got := needsCharsetDecoding(tt.contentType); got != tt.want {
+			t.Errorf("needsCharsetDecoding(%q) = %t, want %t", tt.contentType, got, tt.want)
+		}
+	}
+}
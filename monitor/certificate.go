@@ -0,0 +1,176 @@
+package monitor
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// typeCertificate is the monitor type that watches a host's TLS certificate
+// instead of a page.
+const typeCertificate = "certificate"
+
+const (
+	defaultCertificateWarnDays = 14
+	certificateDialTimeout     = 30 * time.Second
+)
+
+// CertificateSettings configures a certificate monitor.
+type CertificateSettings struct {
+	// WarnDays is how many days before expiry the certificate is reported as
+	// expiring. It defaults to 14.
+	WarnDays int `json:"warnDays,omitempty"`
+}
+
+// CertificateClient connects to a host and returns the certificate chain it
+// presents, without sending an HTTP request. It always connects directly.
+type CertificateClient struct{}
+
+// GetContent implements MonitorClient for CertificateClient. The response
+// body is empty; the chain is in Response.TLS.
+func (c *CertificateClient) GetContent(r Request) (*Response, error) {
+	host, addr, err := certificateAddress(r.URL)
+	if err != nil {
+		return nil, err
+	}
+	// Verification is left to the report, so that an invalid certificate is
+	// described instead of failing the check.
+	config := &tls.Config{}
+	if r.TLS != nil {
+		if config, err = r.TLS.config(); err != nil {
+			return nil, err
+		}
+	}
+	config.ServerName = host
+	config.InsecureSkipVerify = true
+
+	dialer := &net.Dialer{Timeout: certificateDialTimeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", addr, config)
+	if err != nil {
+		return nil, fmt.Errorf("certificate: dial %s: %w", addr, err)
+	}
+	state := conn.ConnectionState()
+	conn.Close()
+	if len(state.PeerCertificates) == 0 {
+		return nil, fmt.Errorf("certificate: %s presented no certificate", addr)
+	}
+	return &Response{
+		Body:       http.NoBody,
+		URL:        r.URL,
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		TLS:        &state,
+	}, nil
+}
+
+// certificateAddress returns the host name and dial address for a monitor
+// URL, which may be a full URL or just "host" or "host:port". The port
+// defaults to 443.
+func certificateAddress(raw string) (host, addr string, err error) {
+	target := raw
+	if strings.Contains(raw, "://") {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return "", "", fmt.Errorf("certificate: parse %q: %w", raw, err)
+		}
+		target = u.Host
+	}
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		host, port = target, "443"
+	}
+	if host == "" {
+		return "", "", fmt.Errorf("certificate: no host in %q", raw)
+	}
+	return host, net.JoinHostPort(host, port), nil
+}
+
+// report describes the certificate in resp, one property per line. Only
+// properties that change when the certificate is replaced or crosses the
+// warning threshold are included, so the report can be compared like any
+// other content.
+func (s *CertificateSettings) report(resp *Response, settings *TLSSettings, now time.Time) (string, error) {
+	if resp.TLS == nil || len(resp.TLS.PeerCertificates) == 0 {
+		return "", errors.New("certificate: response has no certificate")
+	}
+	chain := resp.TLS.PeerCertificates
+	leaf := chain[0]
+
+	names := append([]string(nil), leaf.DNSNames...)
+	for _, ip := range leaf.IPAddresses {
+		names = append(names, ip.String())
+	}
+	fingerprint := sha256.Sum256(leaf.Raw)
+
+	lines := []string{
+		"Subject: " + leaf.Subject.String(),
+		"Issuer: " + leaf.Issuer.String(),
+		"Names: " + strings.Join(names, ", "),
+		"Serial: " + leaf.SerialNumber.Text(16),
+		fmt.Sprintf("Fingerprint: %x", fingerprint),
+		"Valid from: " + leaf.NotBefore.UTC().Format(time.RFC3339),
+		"Valid until: " + leaf.NotAfter.UTC().Format(time.RFC3339),
+		"Verification: " + verifyCertificate(chain, resp.URL, settings, now),
+		"Expiry: " + s.expiry(leaf, now),
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// expiry summarises how close leaf is to expiring.
+func (s *CertificateSettings) expiry(leaf *x509.Certificate, now time.Time) string {
+	warnDays := defaultCertificateWarnDays
+	if s != nil && s.WarnDays > 0 {
+		warnDays = s.WarnDays
+	}
+	switch {
+	case now.Before(leaf.NotBefore):
+		return "not yet valid"
+	case !now.Before(leaf.NotAfter):
+		return "expired"
+	case leaf.NotAfter.Sub(now) <= time.Duration(warnDays)*24*time.Hour:
+		return fmt.Sprintf("expires within %d days", warnDays)
+	}
+	return "ok"
+}
+
+// verifyCertificate checks chain against the trusted roots and the host name
+// of rawURL. The result never contains the current time, which would make
+// every check look like a change.
+func verifyCertificate(chain []*x509.Certificate, rawURL string, settings *TLSSettings, now time.Time) string {
+	if settings != nil && settings.InsecureSkipVerify {
+		return "skipped"
+	}
+	host, _, err := certificateAddress(rawURL)
+	if err != nil {
+		return "failed: " + err.Error()
+	}
+	roots, err := settings.rootCAs()
+	if err != nil {
+		return "failed: " + err.Error()
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err = chain[0].Verify(x509.VerifyOptions{
+		DNSName:       host,
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+	})
+	var invalid x509.CertificateInvalidError
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.As(err, &invalid) && invalid.Reason == x509.Expired:
+		return "failed: certificate is expired or not yet valid"
+	}
+	return "failed: " + err.Error()
+}
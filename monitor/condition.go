@@ -0,0 +1,154 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// When a condition's text is tested.
+const (
+	whenPresent     = "present"
+	whenAbsent      = "absent"
+	whenAppeared    = "appeared"
+	whenDisappeared = "disappeared"
+)
+
+// Condition decides whether a change is notified. A condition is either a
+// group, which matches when All or Any of its conditions match, or a test
+// for the text in Contains or the expression in Regex.
+//
+// When says what the test looks for: "present" (the default) and "absent"
+// look at the new content only, while "appeared" and "disappeared" compare
+// it with the previous content, so that "Sold out" disappearing can be
+// notified.
+type Condition struct {
+	All []Condition `json:"all,omitempty"`
+	Any []Condition `json:"any,omitempty"`
+
+	Contains   string `json:"contains,omitempty"`
+	Regex      string `json:"regex,omitempty"`
+	IgnoreCase bool   `json:"ignoreCase,omitempty"`
+	When       string `json:"when,omitempty"`
+}
+
+func (c Condition) group() bool {
+	return c.All != nil || c.Any != nil
+}
+
+// match evaluates c against the previous and the new content.
+func (c Condition) match(prev, next string) (bool, error) {
+	if c.group() {
+		for _, sub := range c.All {
+			ok, err := sub.match(prev, next)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+		if c.Any == nil {
+			return true, nil
+		}
+		for _, sub := range c.Any {
+			ok, err := sub.match(prev, next)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+	}
+
+	found, err := c.finder()
+	if err != nil {
+		return false, err
+	}
+	switch c.When {
+	case "", whenPresent:
+		return found(next), nil
+	case whenAbsent:
+		return !found(next), nil
+	case whenAppeared:
+		return found(next) && !found(prev), nil
+	case whenDisappeared:
+		return found(prev) && !found(next), nil
+	default:
+		return false, fmt.Errorf("condition: unknown when %q", c.When)
+	}
+}
+
+// finder returns a function reporting whether content holds c's text.
+func (c Condition) finder() (func(string) bool, error) {
+	if c.Regex != "" {
+		pattern := c.Regex
+		if c.IgnoreCase {
+			pattern = "(?i)" + pattern
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("condition: compile %q: %w", c.Regex, err)
+		}
+		return re.MatchString, nil
+	}
+	if c.IgnoreCase {
+		text := strings.ToLower(c.Contains)
+		return func(content string) bool { return strings.Contains(strings.ToLower(content), text) }, nil
+	}
+	return func(content string) bool { return strings.Contains(content, c.Contains) }, nil
+}
+
+// validate checks the condition and its subconditions without evaluating
+// them.
+func (c Condition) validate() error {
+	if c.group() {
+		if c.Contains != "" || c.Regex != "" || c.When != "" {
+			return errors.New("condition: a group cannot also test text")
+		}
+		for _, sub := range slices.Concat(c.All, c.Any) {
+			if err := sub.validate(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if c.Contains == "" && c.Regex == "" {
+		return errors.New("condition: needs contains, regex, all or any")
+	}
+	if c.Contains != "" && c.Regex != "" {
+		return errors.New("condition: set either contains or regex, not both")
+	}
+	switch c.When {
+	case "", whenPresent, whenAbsent, whenAppeared, whenDisappeared:
+	default:
+		return fmt.Errorf("condition: unknown when %q", c.When)
+	}
+	_, err := c.finder()
+	return err
+}
+
+// condition expresses the legacy filters as a condition. As before, a change
+// is notified when any Contains text is present or any NotContains text is
+// absent.
+func (f Filters) condition() Condition {
+	anyOf := make([]Condition, 0, len(f.Contains)+len(f.NotContains))
+	for _, text := range f.Contains {
+		anyOf = append(anyOf, Condition{Contains: text})
+	}
+	for _, text := range f.NotContains {
+		anyOf = append(anyOf, Condition{Contains: text, When: whenAbsent})
+	}
+	return Condition{Any: anyOf}
+}
+
+// conditionsMet reports whether a change from prev to next satisfies both
+// the monitor's filters and its conditions.
+func (m *Monitor) conditionsMet(prev, next string) (bool, error) {
+	all := []Condition{}
+	if m.Filters != nil {
+		all = append(all, m.Filters.condition())
+	}
+	if m.Conditions != nil {
+		all = append(all, *m.Conditions)
+	}
+	return Condition{All: all}.match(prev, next)
+}
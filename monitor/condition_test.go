@@ -0,0 +1,102 @@
+package monitor
+
+import "testing"
+
+func TestConditionMatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		condition  Condition
+		prev, next string
+		want       bool
+	}{
+		{"present", Condition{Contains: "In stock"}, "", "In stock", true},
+		{"not present", Condition{Contains: "In stock"}, "", "Sold out", false},
+		{"present is case sensitive", Condition{Contains: "In stock"}, "", "in stock", false},
+		{"ignore case", Condition{Contains: "In stock", IgnoreCase: true}, "", "IN STOCK", true},
+		{"absent", Condition{Contains: "Sold out", When: whenAbsent}, "Sold out", "In stock", true},
+		{"not absent", Condition{Contains: "Sold out", When: whenAbsent}, "", "Sold out", false},
+		{"appeared", Condition{Contains: "Sale", When: whenAppeared}, "Price", "Sale price", true},
+		{"already there", Condition{Contains: "Sale", When: whenAppeared}, "Sale", "Sale price", false},
+		{"disappeared", Condition{Contains: "Sold out", When: whenDisappeared}, "Sold out", "Add to cart", true},
+		{"never there", Condition{Contains: "Sold out", When: whenDisappeared}, "Add to cart", "Add to cart", false},
+		{"regex", Condition{Regex: `v\d+\.\d+`}, "", "Release v2.1", true},
+		{"regex ignore case", Condition{Regex: `^release`, IgnoreCase: true}, "", "Release v2.1", true},
+		{"regex no match", Condition{Regex: `v\d+\.\d+`}, "", "Release soon", false},
+		{"all", Condition{All: []Condition{{Contains: "a"}, {Contains: "b"}}}, "", "a b", true},
+		{"all with one failing", Condition{All: []Condition{{Contains: "a"}, {Contains: "c"}}}, "", "a b", false},
+		{"any", Condition{Any: []Condition{{Contains: "x"}, {Contains: "b"}}}, "", "a b", true},
+		{"any with none matching", Condition{Any: []Condition{{Contains: "x"}, {Contains: "y"}}}, "", "a b", false},
+		{"empty all", Condition{All: []Condition{}}, "", "a", true},
+		{"empty any", Condition{Any: []Condition{}}, "", "a", false},
+		{"all and any", Condition{
+			All: []Condition{{Contains: "In stock"}},
+			Any: []Condition{{Contains: "Size M"}, {Contains: "Size L"}},
+		}, "", "In stock: Size L", true},
+		{"nested groups", Condition{Any: []Condition{
+			{All: []Condition{{Contains: "Sale"}, {Contains: "Sold out", When: whenAbsent}}},
+			{Contains: "Back in stock", When: whenAppeared},
+		}}, "Sold out", "Back in stock", true},
+	}
+	for _, tt := range tests {
+		got, err := tt.condition.match(tt.prev, tt.next)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: match = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestConditionValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition Condition
+		ok        bool
+	}{
+		{"contains", Condition{Contains: "a"}, true},
+		{"regex", Condition{Regex: "a+", When: whenAppeared}, true},
+		{"group", Condition{All: []Condition{{Contains: "a"}}, Any: []Condition{{Regex: "b"}}}, true},
+		{"empty", Condition{}, false},
+		{"contains and regex", Condition{Contains: "a", Regex: "a"}, false},
+		{"group with text", Condition{All: []Condition{{Contains: "a"}}, Contains: "b"}, false},
+		{"unknown when", Condition{Contains: "a", When: "sometimes"}, false},
+		{"invalid regex", Condition{Regex: "("}, false},
+		{"invalid nested condition", Condition{Any: []Condition{{Contains: "a"}, {Regex: "["}}}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.condition.validate(); (err == nil) != tt.ok {
+			t.Errorf("%s: validate = %v, want ok = %t", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestConditionsMet(t *testing.T) {
+	tests := []struct {
+		name       string
+		monitor    *Monitor
+		prev, next string
+		want       bool
+	}{
+		{"nothing set", &Monitor{}, "a", "b", true},
+		{"filter contains", &Monitor{Filters: &Filters{Contains: []string{"b"}}}, "a", "b", true},
+		{"filter contains missing", &Monitor{Filters: &Filters{Contains: []string{"c"}}}, "a", "b", false},
+		{"filter not contains", &Monitor{Filters: &Filters{NotContains: []string{"Sold out"}}}, "", "In stock", true},
+		{"filter and condition", &Monitor{
+			Filters:    &Filters{Contains: []string{"b"}},
+			Conditions: &Condition{Contains: "c"},
+		}, "a", "b", false},
+		{"condition alone", &Monitor{Conditions: &Condition{Contains: "b", When: whenAppeared}}, "a", "b", true},
+	}
+	for _, tt := range tests {
+		got, err := tt.monitor.conditionsMet(tt.prev, tt.next)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: conditionsMet = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package monitor
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// validatorsKind is the storage suffix for a monitor's cache validators.
+const validatorsKind = "validators"
+
+// Validators are the cache validators of the last response a monitor
+// processed. Sending them back lets the server answer "304 Not Modified"
+// instead of the whole document.
+type Validators struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	// Config fingerprints the monitor settings the response was processed
+	// with, so that editing a monitor forces a full fetch.
+	Config string `json:"config,omitempty"`
+}
+
+func (v *Validators) empty() bool {
+	return v == nil || v.ETag == "" && v.LastModified == ""
+}
+
+// apply adds the conditional request headers for v to h.
+func (v *Validators) apply(h http.Header) {
+	if v.empty() {
+		return
+	}
+	if v.ETag != "" {
+		h.Set("If-None-Match", v.ETag)
+	}
+	if v.LastModified != "" {
+		h.Set("If-Modified-Since", v.LastModified)
+	}
+}
+
+// validatorsFrom reads the cache validators of resp, or nil if it has none.
+func validatorsFrom(resp *http.Response) *Validators {
+	v := &Validators{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	if v.empty() {
+		return nil
+	}
+	return v
+}
+
+// loadValidators returns the validators stored for the monitor, or nil when
+// there are none or they were recorded under different settings.
+func (m *Monitor) loadValidators() *Validators {
+	raw := m.storage.GetContent(stateKey(m.id, validatorsKind))
+	if raw == "" {
+		return nil
+	}
+	var v Validators
+	if err := json.Unmarshal([]byte(raw), &v); err != nil {
+		log.Printf("monitor: parse stored validators: %v", err)
+		return nil
+	}
+	if v.Config != m.fingerprint {
+		return nil
+	}
+	return &v
+}
+
+// saveValidators records the validators of the response being processed. A
+// response without validators clears the stored ones.
+func (m *Monitor) saveValidators(v *Validators) {
+	key := stateKey(m.id, validatorsKind)
+	if v.empty() {
+		if err := m.storage.DeleteContent(key); err != nil {
+			log.Printf("monitor: delete validators: %v", err)
+		}
+		return
+	}
+	v.Config = m.fingerprint
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("monitor: encode validators: %v", err)
+		return
+	}
+	m.storage.WriteContent(key, string(data))
+}
@@ -0,0 +1,318 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+	"golang.org/x/net/publicsuffix"
+)
+
+// cookiesKind is the storage suffix under which a monitor's cookie jar is kept.
+const cookiesKind = "cookies"
+
+// CookieSettings gives a monitor its own cookie jar. Seed cookies are added
+// whenever the jar holds no cookie with the same name, domain and path, so a
+// consent cookie can be provided up front and later replaced by the site.
+type CookieSettings struct {
+	// Persist keeps the jar in storage between checks. Without it the jar
+	// only lives for a single check.
+	Persist bool     `json:"persist,omitempty"`
+	Seed    []Cookie `json:"seed,omitempty"`
+}
+
+// Cookie is a single cookie as kept in a CookieJar.
+type Cookie struct {
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Domain   string    `json:"domain"`
+	Path     string    `json:"path,omitempty"`
+	Expires  time.Time `json:"expires,omitzero"`
+	Secure   bool      `json:"secure,omitempty"`
+	HTTPOnly bool      `json:"httpOnly,omitempty"`
+	// HostOnly restricts the cookie to Domain itself, excluding subdomains.
+	HostOnly bool `json:"hostOnly,omitempty"`
+}
+
+// CookieJar is an http.CookieJar whose contents can be listed, which lets it
+// be persisted and handed to Chrome.
+type CookieJar struct {
+	mu      sync.Mutex
+	cookies []Cookie
+}
+
+// newCookieJar returns a jar holding cookies followed by any seed cookie
+// that is not already present. A seed cookie without a domain belongs to the
+// host of rawURL only.
+func newCookieJar(cookies []Cookie, seed []Cookie, rawURL string) *CookieJar {
+	j := &CookieJar{}
+	for _, c := range cookies {
+		j.set(c)
+	}
+	for _, c := range seed {
+		if c.Path == "" {
+			c.Path = "/"
+		}
+		c.Domain = strings.ToLower(strings.TrimPrefix(c.Domain, "."))
+		if c.Domain == "" {
+			if u, err := url.Parse(rawURL); err == nil {
+				c.Domain = strings.ToLower(u.Hostname())
+				c.HostOnly = true
+			}
+		}
+		if j.index(c) < 0 {
+			j.set(c)
+		}
+	}
+	return j
+}
+
+// loadCookieJar reads a persisted jar from storage and applies the seed.
+func loadCookieJar(storage Storage, id string, seed []Cookie, rawURL string) *CookieJar {
+	var cookies []Cookie
+	if raw := storage.GetContent(stateKey(id, cookiesKind)); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &cookies); err != nil {
+			log.Printf("monitor: parse stored cookies: %v", err)
+		}
+	}
+	return newCookieJar(cookies, seed, rawURL)
+}
+
+// save writes the jar's unexpired cookies to storage.
+func (j *CookieJar) save(storage Storage, id string) {
+	data, err := json.Marshal(j.all())
+	if err != nil {
+		log.Printf("monitor: encode cookies: %v", err)
+		return
+	}
+	storage.WriteContent(stateKey(id, cookiesKind), string(data))
+}
+
+// SetCookies implements http.CookieJar.
+func (j *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	host := strings.ToLower(u.Hostname())
+	for _, hc := range cookies {
+		c := Cookie{
+			Name:     hc.Name,
+			Value:    hc.Value,
+			Domain:   strings.ToLower(strings.TrimPrefix(hc.Domain, ".")),
+			Path:     hc.Path,
+			Secure:   hc.Secure,
+			HTTPOnly: hc.HttpOnly,
+		}
+		switch {
+		case c.Domain == "":
+			c.Domain = host
+			c.HostOnly = true
+		case isPublicSuffix(c.Domain):
+			// Like browsers, only the public suffix itself may set a cookie
+			// for it, and that cookie stays with the host.
+			if c.Domain != host {
+				continue
+			}
+			c.HostOnly = true
+		case !domainMatch(host, c.Domain):
+			continue
+		}
+		if c.Path == "" || !strings.HasPrefix(c.Path, "/") {
+			c.Path = defaultCookiePath(u.Path)
+		}
+		switch {
+		case hc.MaxAge < 0:
+			c.Expires = time.Unix(1, 0)
+		case hc.MaxAge > 0:
+			c.Expires = time.Now().Add(time.Duration(hc.MaxAge) * time.Second)
+		default:
+			c.Expires = hc.Expires
+		}
+		j.set(c)
+	}
+}
+
+// isPublicSuffix reports whether domain is a suffix such as "com" or "co.uk"
+// under which anyone can register a name.
+func isPublicSuffix(domain string) bool {
+	suffix, _ := publicsuffix.PublicSuffix(domain)
+	return suffix == domain
+}
+
+// Cookies implements http.CookieJar.
+func (j *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	host := strings.ToLower(u.Hostname())
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	var result []*http.Cookie
+	for _, c := range j.all() {
+		if c.HostOnly && host != c.Domain || !c.HostOnly && !domainMatch(host, c.Domain) {
+			continue
+		}
+		if !pathMatch(path, c.Path) || c.Secure && u.Scheme != "https" {
+			continue
+		}
+		result = append(result, &http.Cookie{Name: c.Name, Value: c.Value})
+	}
+	return result
+}
+
+// all returns a copy of the unexpired cookies in the jar.
+func (j *CookieJar) all() []Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now()
+	result := make([]Cookie, 0, len(j.cookies))
+	for _, c := range j.cookies {
+		if c.Expires.IsZero() || c.Expires.After(now) {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// replace swaps the jar's contents for cookies.
+func (j *CookieJar) replace(cookies []Cookie) {
+	j.mu.Lock()
+	j.cookies = nil
+	j.mu.Unlock()
+	for _, c := range cookies {
+		j.set(c)
+	}
+}
+
+// set stores c, replacing any cookie with the same name, domain and path. An
+// already expired cookie removes the stored one instead.
+func (j *CookieJar) set(c Cookie) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	i := j.index(c)
+	expired := !c.Expires.IsZero() && !c.Expires.After(time.Now())
+	switch {
+	case i >= 0 && expired:
+		j.cookies = append(j.cookies[:i], j.cookies[i+1:]...)
+	case i >= 0:
+		j.cookies[i] = c
+	case !expired:
+		j.cookies = append(j.cookies, c)
+	}
+}
+
+func (j *CookieJar) index(c Cookie) int {
+	for i, existing := range j.cookies {
+		if existing.Name == c.Name && existing.Domain == c.Domain && existing.Path == c.Path {
+			return i
+		}
+	}
+	return -1
+}
+
+// cookieParams converts the jar into parameters for network.SetCookies.
+func (j *CookieJar) cookieParams() []*network.CookieParam {
+	cookies := j.all()
+	params := make([]*network.CookieParam, 0, len(cookies))
+	for _, c := range cookies {
+		p := &network.CookieParam{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Secure:   c.Secure,
+			HTTPOnly: c.HTTPOnly,
+		}
+		if c.HostOnly {
+			scheme := "http"
+			if c.Secure {
+				scheme = "https"
+			}
+			p.URL = scheme + "://" + c.Domain + c.Path
+		} else {
+			p.Domain = "." + c.Domain
+		}
+		if !c.Expires.IsZero() {
+			t := cdp.TimeSinceEpoch(c.Expires)
+			p.Expires = &t
+		}
+		params = append(params, p)
+	}
+	return params
+}
+
+// cookieURLs lists one URL per domain in the jar, plus extra, so that the
+// browser can be asked for every cookie the jar is responsible for.
+func (j *CookieJar) cookieURLs(extra ...string) []string {
+	seen := make(map[string]struct{})
+	var urls []string
+	add := func(u string) {
+		if _, ok := seen[u]; !ok && u != "" {
+			seen[u] = struct{}{}
+			urls = append(urls, u)
+		}
+	}
+	for _, u := range extra {
+		add(u)
+	}
+	for _, c := range j.all() {
+		add("https://" + c.Domain + c.Path)
+	}
+	return urls
+}
+
+// syncFromBrowser replaces the jar's contents with the cookies the browser
+// holds for urls after a page load.
+func (j *CookieJar) syncFromBrowser(ctx context.Context, urls []string) error {
+	cookies, err := network.GetCookies().WithURLs(urls).Do(ctx)
+	if err != nil {
+		return err
+	}
+	result := make([]Cookie, 0, len(cookies))
+	for _, bc := range cookies {
+		c := Cookie{
+			Name:     bc.Name,
+			Value:    bc.Value,
+			Domain:   strings.TrimPrefix(bc.Domain, "."),
+			Path:     bc.Path,
+			Secure:   bc.Secure,
+			HTTPOnly: bc.HTTPOnly,
+			HostOnly: !strings.HasPrefix(bc.Domain, "."),
+		}
+		if !bc.Session && bc.Expires > 0 {
+			c.Expires = time.Unix(int64(bc.Expires), 0)
+		}
+		result = append(result, c)
+	}
+	j.replace(result)
+	return nil
+}
+
+func domainMatch(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+func pathMatch(requestPath, cookiePath string) bool {
+	if !strings.HasPrefix(requestPath, cookiePath) {
+		return false
+	}
+	return len(requestPath) == len(cookiePath) ||
+		strings.HasSuffix(cookiePath, "/") ||
+		requestPath[len(cookiePath)] == '/'
+}
+
+func defaultCookiePath(requestPath string) string {
+	i := strings.LastIndex(requestPath, "/")
+	if i <= 0 {
+		return "/"
+	}
+	return requestPath[:i]
+}
+
+// stateKey names a piece of per-monitor state kept next to the monitor's
+// recorded content.
+func stateKey(id, kind string) string {
+	return id + "." + kind
+}
@@ -0,0 +1,118 @@
+package monitor
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+)
+
+// cookieNames returns the names of the cookies jar sends to rawURL.
+func cookieNames(t *testing.T, jar *CookieJar, rawURL string) []string {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, c := range jar.Cookies(u) {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+func TestCookieJarDomains(t *testing.T) {
+	tests := []struct {
+		name   string
+		from   string
+		cookie http.Cookie
+		to     string
+		sent   bool
+	}{
+		{"host only", "https://shop.example.com/", http.Cookie{Name: "a"}, "https://shop.example.com/", true},
+		{"host only to subdomain", "https://example.com/", http.Cookie{Name: "a"}, "https://www.example.com/", false},
+		{"domain to subdomain", "https://example.com/", http.Cookie{Name: "a", Domain: "example.com"}, "https://www.example.com/", true},
+		{"leading dot", "https://www.example.com/", http.Cookie{Name: "a", Domain: ".example.com"}, "https://example.com/", true},
+		{"domain to other site", "https://example.com/", http.Cookie{Name: "a", Domain: "example.com"}, "https://badexample.com/", false},
+		{"foreign domain", "https://example.com/", http.Cookie{Name: "a", Domain: "example.org"}, "https://example.org/", false},
+		{"public suffix", "https://a.example.co.uk/", http.Cookie{Name: "a", Domain: "co.uk"}, "https://evil.co.uk/", false},
+		{"top-level domain", "https://example.com/", http.Cookie{Name: "a", Domain: "com"}, "https://other.com/", false},
+		{"secure over http", "https://example.com/", http.Cookie{Name: "a", Secure: true}, "http://example.com/", false},
+		{"secure over https", "https://example.com/", http.Cookie{Name: "a", Secure: true}, "https://example.com/", true},
+		{"expired", "https://example.com/", http.Cookie{Name: "a", MaxAge: -1}, "https://example.com/", false},
+		{"case insensitive host", "https://Example.COM/", http.Cookie{Name: "a"}, "https://example.com/", true},
+	}
+	for _, tt := range tests {
+		jar := newCookieJar(nil, nil, "")
+		from, _ := url.Parse(tt.from)
+		c := tt.cookie
+		jar.SetCookies(from, []*http.Cookie{&c})
+		if got := len(cookieNames(t, jar, tt.to)) == 1; got != tt.sent {
+			t.Errorf("%s: cookie sent to %s = %t, want %t", tt.name, tt.to, got, tt.sent)
+		}
+	}
+}
+
+func TestCookieJarPaths(t *testing.T) {
+	tests := []struct {
+		from, path, to string
+		sent           bool
+	}{
+		{"https://example.com/", "/account", "https://example.com/account", true},
+		{"https://example.com/", "/account", "https://example.com/account/orders", true},
+		{"https://example.com/", "/account", "https://example.com/accounts", false},
+		{"https://example.com/", "/account/", "https://example.com/account/orders", true},
+		{"https://example.com/", "/account", "https://example.com/", false},
+		{"https://example.com/shop/cart", "", "https://example.com/shop/items", true},
+		{"https://example.com/shop/cart", "", "https://example.com/", false},
+		{"https://example.com/cart", "", "https://example.com/", true},
+	}
+	for _, tt := range tests {
+		jar := newCookieJar(nil, nil, "")
+		from, _ := url.Parse(tt.from)
+		jar.SetCookies(from, []*http.Cookie{{Name: "a", Path: tt.path}})
+		if got := len(cookieNames(t, jar, tt.to)) == 1; got != tt.sent {
+			t.Errorf("path %q set from %s: sent to %s = %t, want %t", tt.path, tt.from, tt.to, got, tt.sent)
+		}
+	}
+}
+
+func TestCookieJarReplaces(t *testing.T) {
+	jar := newCookieJar(nil, nil, "")
+	u, _ := url.Parse("https://example.com/")
+	jar.SetCookies(u, []*http.Cookie{{Name: "session", Value: "1"}})
+	jar.SetCookies(u, []*http.Cookie{{Name: "session", Value: "2"}})
+	cookies := jar.Cookies(u)
+	if len(cookies) != 1 || cookies[0].Value != "2" {
+		t.Fatalf("cookies = %v, want session=2 alone", cookies)
+	}
+	jar.SetCookies(u, []*http.Cookie{{Name: "session", Expires: time.Unix(1, 0)}})
+	if cookies := jar.Cookies(u); len(cookies) != 0 {
+		t.Fatalf("cookies after expiry = %v, want none", cookies)
+	}
+}
+
+func TestCookieJarSeed(t *testing.T) {
+	stored := []Cookie{{Name: "consent", Value: "site", Domain: "example.com", Path: "/"}}
+	seed := []Cookie{
+		{Name: "consent", Value: "seed", Domain: ".Example.com"},
+		{Name: "lang", Value: "da"},
+	}
+	jar := newCookieJar(stored, seed, "https://www.example.com/page")
+
+	u, _ := url.Parse("https://www.example.com/")
+	values := make(map[string]string)
+	for _, c := range jar.Cookies(u) {
+		values[c.Name] = c.Value
+	}
+	if values["consent"] != "site" {
+		t.Errorf("consent = %q, want the stored value to win over the seed", values["consent"])
+	}
+	if values["lang"] != "da" {
+		t.Errorf("lang = %q, want the seed cookie without a domain for the monitor's host", values["lang"])
+	}
+	if names := cookieNames(t, jar, "https://shop.example.com/"); !slices.Equal(names, []string{"consent"}) {
+		t.Errorf("cookies for another subdomain = %q, want only consent", names)
+	}
+}
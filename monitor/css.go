@@ -0,0 +1,88 @@
+package monitor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+)
+
+// CSS output modes, selected by a suffix on the selector path.
+const (
+	cssText      = "text"
+	cssInnerHTML = "html"
+	cssOuterHTML = "outer-html"
+	cssAttr      = "attr"
+)
+
+// cssPath is a CSS selector path split into the selector and what to output
+// for each element it matches. Paths take the form "a.download::attr(href)",
+// "div.notes::html", "div.notes::outer-html" or "p::text"; without a suffix
+// the text is output.
+type cssPath struct {
+	selector string
+	mode     string
+	attr     string
+	explicit bool
+}
+
+func parseCSSPath(p string) (cssPath, error) {
+	i := strings.LastIndex(p, "::")
+	if i < 0 {
+		return cssPath{selector: p, mode: cssText}, nil
+	}
+	path := cssPath{selector: strings.TrimSpace(p[:i]), explicit: true}
+	switch suffix := strings.TrimSpace(p[i+2:]); {
+	case suffix == cssText, suffix == cssInnerHTML, suffix == cssOuterHTML:
+		path.mode = suffix
+	case strings.HasPrefix(suffix, "attr(") && strings.HasSuffix(suffix, ")"):
+		path.mode = cssAttr
+		path.attr = strings.TrimSpace(suffix[len("attr(") : len(suffix)-1])
+		if path.attr == "" {
+			return cssPath{}, fmt.Errorf("css: empty attribute name in %q", p)
+		}
+	default:
+		// Not an output suffix, so "::" belongs to the selector itself.
+		return cssPath{selector: p, mode: cssText}, nil
+	}
+	return path, nil
+}
+
+// output returns what the path outputs for each element in sel.
+func (p cssPath) output(sel *goquery.Selection) []string {
+	var out []string
+	sel.Each(func(_ int, s *goquery.Selection) {
+		switch p.mode {
+		case cssInnerHTML:
+			if h, err := s.Html(); err == nil {
+				out = append(out, h)
+			}
+		case cssOuterHTML:
+			if h, err := goquery.OuterHtml(s); err == nil {
+				out = append(out, h)
+			}
+		case cssAttr:
+			if v, ok := s.Attr(p.attr); ok {
+				out = append(out, v)
+			}
+		default:
+			out = append(out, s.Text())
+		}
+	})
+	return out
+}
+
+// validateCSSPaths checks that every path has a valid selector and output.
+func validateCSSPaths(paths []string) error {
+	for _, p := range paths {
+		path, err := parseCSSPath(p)
+		if err != nil {
+			return err
+		}
+		if _, err := cascadia.Compile(path.selector); err != nil {
+			return fmt.Errorf("css: invalid selector %q: %w", path.selector, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package monitor
+
+import "strings"
+
+// splitLines splits content into lines, returning none for empty content.
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(content, "\n")
+}
+
+// lineChanges compares two versions of content line by line. It returns the
+// lines only found in next and those only found in prev, each in the order
+// they appear. Repeated lines are counted, so a line that appears twice where
+// it used to appear once is reported as added once. Lines that merely moved
+// are not reported.
+func lineChanges(prev, next string) (added, removed []string) {
+	counts := make(map[string]int)
+	for _, line := range splitLines(prev) {
+		counts[line]++
+	}
+	for _, line := range splitLines(next) {
+		if counts[line] > 0 {
+			counts[line]--
+			continue
+		}
+		added = append(added, line)
+	}
+	for _, line := range splitLines(prev) {
+		if counts[line] > 0 {
+			counts[line]--
+			removed = append(removed, line)
+		}
+	}
+	return added, removed
+}
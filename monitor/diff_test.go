@@ -0,0 +1,129 @@
+package monitor
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestLineChanges(t *testing.T) {
+	tests := []struct {
+		name           string
+		prev, next     string
+		added, removed []string
+	}{
+		{"unchanged", "a\nb", "a\nb", nil, nil},
+		{"from empty", "", "a\nb", []string{"a", "b"}, nil},
+		{"to empty", "a\nb", "", nil, []string{"a", "b"}},
+		{"line added", "a\nc", "a\nb\nc", []string{"b"}, nil},
+		{"line removed", "a\nb\nc", "a\nc", nil, []string{"b"}},
+		{"line modified", "a\nprice 10\nc", "a\nprice 12\nc", []string{"price 12"}, []string{"price 10"}},
+		{"moved", "a\nb\nc", "c\na\nb", nil, nil},
+		{"repeated once more", "a\nb", "a\nb\na", []string{"a"}, nil},
+		{"repeated once less", "a\na\nb", "a\nb", nil, []string{"a"}},
+		{"order kept", "x", "c\nx\na\nb", []string{"c", "a", "b"}, nil},
+	}
+	for _, tt := range tests {
+		added, removed := lineChanges(tt.prev, tt.next)
+		if !slices.Equal(added, tt.added) || !slices.Equal(removed, tt.removed) {
+			t.Errorf("%s: lineChanges = %q, %q, want %q, %q", tt.name, added, removed, tt.added, tt.removed)
+		}
+	}
+}
+
+func TestMeasureChange(t *testing.T) {
+	tests := []struct {
+		name       string
+		prev, next string
+		want       ChangeMagnitude
+	}{
+		{"identical", "a\nb", "a\nb", ChangeMagnitude{Similarity: 100}},
+		{"one of four lines modified", "aa\nbb\ncc\ndd", "aa\nbb\ncc\nee", ChangeMagnitude{
+			AddedLines: 1, RemovedLines: 1, LinesPercent: 25, CharsPercent: 25,
+		}},
+		{"line added", "aa\nbb", "aa\nbb\ncc\ndd", ChangeMagnitude{
+			AddedLines: 2, LinesPercent: 50, CharsPercent: 100 * 4.0 / 12,
+		}},
+		{"everything replaced", "abc", "xyz", ChangeMagnitude{
+			AddedLines: 1, RemovedLines: 1, LinesPercent: 100, CharsPercent: 100,
+		}},
+	}
+	for _, tt := range tests {
+		got := measureChange(tt.prev, tt.next)
+		// Similarity is checked on its own below.
+		if tt.want.Similarity == 0 {
+			got.Similarity = 0
+		}
+		if got.AddedLines != tt.want.AddedLines || got.RemovedLines != tt.want.RemovedLines ||
+			!near(got.LinesPercent, tt.want.LinesPercent) || !near(got.CharsPercent, tt.want.CharsPercent) ||
+			!near(got.Similarity, tt.want.Similarity) {
+			t.Errorf("%s: measureChange = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"night", "night", 100},
+		{"night", "nacht", 25},
+		{"abc", "xyz", 0},
+		{"a", "b", 0},
+		{"", "", 100},
+	}
+	for _, tt := range tests {
+		if got := similarity(tt.a, tt.b); !near(got, tt.want) {
+			t.Errorf("similarity(%q, %q) = %g, want %g", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestThresholdSignificant(t *testing.T) {
+	change := ChangeMagnitude{AddedLines: 2, RemovedLines: 1, LinesPercent: 20, CharsPercent: 5, Similarity: 90}
+	tests := []struct {
+		name      string
+		threshold *Threshold
+		want      bool
+	}{
+		{"no threshold", nil, true},
+		{"empty threshold", &Threshold{}, true},
+		{"lines met", &Threshold{LinesPercent: 20}, true},
+		{"lines not met", &Threshold{LinesPercent: 25}, false},
+		{"chars not met", &Threshold{CharsPercent: 10}, false},
+		{"min lines counts modified lines once", &Threshold{MinLines: 2}, true},
+		{"min lines not met", &Threshold{MinLines: 3}, false},
+		{"similar enough", &Threshold{MaxSimilarity: 95}, true},
+		{"too similar", &Threshold{MaxSimilarity: 80}, false},
+		{"every criterion must be met", &Threshold{LinesPercent: 10, CharsPercent: 10}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.threshold.significant(change); got != tt.want {
+			t.Errorf("%s: significant = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestThresholdValidate(t *testing.T) {
+	tests := []struct {
+		threshold *Threshold
+		ok        bool
+	}{
+		{nil, true},
+		{&Threshold{LinesPercent: 100, CharsPercent: 0, MaxSimilarity: 50, MinLines: 1}, true},
+		{&Threshold{LinesPercent: 101}, false},
+		{&Threshold{CharsPercent: -1}, false},
+		{&Threshold{MaxSimilarity: 150}, false},
+		{&Threshold{MinLines: -1}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.threshold.validate(); (err == nil) != tt.ok {
+			t.Errorf("validate(%+v) = %v, want ok = %t", tt.threshold, err, tt.ok)
+		}
+	}
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
@@ -0,0 +1,196 @@
+package monitor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/antchfx/xmlquery"
+	"github.com/ledongthuc/pdf"
+)
+
+// Document kinds, which decide how content is extracted from a response.
+const (
+	docHTML = "html"
+	docText = "text"
+	docXML  = "xml"
+	docCSV  = "csv"
+	docPDF  = "pdf"
+)
+
+// sniffLength is how much of a body is inspected when the server does not
+// say what it is sending.
+const sniffLength = 512
+
+// detectDocument works out the kind of document in body from its
+// Content-Type, looking at the body itself when the type is missing or
+// generic. It returns the kind, the media type and a body to read from
+// instead of the original.
+func detectDocument(contentType string, body io.ReadCloser) (string, string, io.ReadCloser) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "" || mediaType == "application/octet-stream" {
+		buffered := bufio.NewReaderSize(body, sniffLength)
+		head, _ := buffered.Peek(sniffLength)
+		mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(head))
+		body = readCloser{buffered, body}
+	}
+	switch {
+	case mediaType == "application/pdf":
+		return docPDF, mediaType, body
+	case mediaType == "text/csv", mediaType == "text/tab-separated-values":
+		return docCSV, mediaType, body
+	case mediaType == "text/plain":
+		return docText, mediaType, body
+	case mediaType == "application/xml", mediaType == "text/xml",
+		strings.HasSuffix(mediaType, "+xml") && mediaType != "application/xhtml+xml":
+		return docXML, mediaType, body
+	case mediaType == "", mediaType == "text/html", mediaType == "application/xhtml+xml":
+		return docHTML, mediaType, body
+	default:
+		return docText, mediaType, body
+	}
+}
+
+// documentText returns the text of a document of the given kind.
+func documentText(body io.ReadCloser, kind string) (string, error) {
+	switch kind {
+	case docHTML:
+		return getHTMLText(body)
+	case docXML:
+		doc, err := xmlquery.Parse(body)
+		if err != nil {
+			return "", fmt.Errorf("xml: parse: %w", err)
+		}
+		var lines []string
+		for _, n := range xmlquery.Find(doc, "//text()") {
+			lines = append(lines, n.Data)
+		}
+		return textLines(strings.Join(lines, "\n")), nil
+	case docCSV:
+		return getCSVSelectorContent(body, nil)
+	default:
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return "", fmt.Errorf("text: read body: %w", err)
+		}
+		return string(data), nil
+	}
+}
+
+// readablePDF replaces a PDF document with its text, so that it can be read
+// like a plain text document. Other documents are returned as they are.
+func readablePDF(body io.ReadCloser, kind string) (io.ReadCloser, string, error) {
+	if kind != docPDF {
+		return body, kind, nil
+	}
+	text, err := getPDFText(body)
+	if err != nil {
+		return nil, "", err
+	}
+	return io.NopCloser(strings.NewReader(text)), docText, nil
+}
+
+// getPDFText extracts the text of every page of a PDF document. The PDF
+// library panics on some malformed documents; that is reported as an error.
+func getPDFText(body io.Reader) (_ string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("pdf: %v", r)
+		}
+	}()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", fmt.Errorf("pdf: read body: %w", err)
+	}
+	r, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("pdf: open: %w", err)
+	}
+	text, err := r.GetPlainText()
+	if err != nil {
+		return "", fmt.Errorf("pdf: extract text: %w", err)
+	}
+	content, err := io.ReadAll(text)
+	if err != nil {
+		return "", fmt.Errorf("pdf: extract text: %w", err)
+	}
+	return textLines(string(content)), nil
+}
+
+// textLines trims every line of text and drops empty ones.
+func textLines(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	lines = slices.DeleteFunc(lines, func(line string) bool { return line == "" })
+	return strings.Join(lines, "\n")
+}
+
+// getCSVSelectorContent returns the rows of a CSV document, one per line
+// with values separated by " | ". Columns selects columns by header name or
+// by 1-based number; without any, every column is returned. The delimiter
+// is a comma, semicolon or tab, whichever the header row uses most.
+func getCSVSelectorContent(body io.Reader, columns []string) (string, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", fmt.Errorf("csv: read body: %w", err)
+	}
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comma = csvDelimiter(data)
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	records, err := r.ReadAll()
+	if err != nil {
+		return "", fmt.Errorf("csv: parse: %w", err)
+	}
+	if len(records) == 0 {
+		return "", nil
+	}
+
+	var indexes []int
+	for _, col := range columns {
+		i := slices.IndexFunc(records[0], func(h string) bool { return strings.EqualFold(strings.TrimSpace(h), col) })
+		if i < 0 {
+			n, err := strconv.Atoi(col)
+			if err != nil || n < 1 {
+				return "", fmt.Errorf("csv: no column %q", col)
+			}
+			i = n - 1
+		}
+		indexes = append(indexes, i)
+	}
+
+	lines := make([]string, 0, len(records))
+	for _, record := range records {
+		values := record
+		if indexes != nil {
+			values = make([]string, len(indexes))
+			for j, i := range indexes {
+				if i < len(record) {
+					values[j] = record[i]
+				}
+			}
+		}
+		lines = append(lines, strings.Join(values, " | "))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func csvDelimiter(data []byte) rune {
+	header, _, _ := bytes.Cut(data, []byte("\n"))
+	delimiter, most := ',', bytes.Count(header, []byte(","))
+	for _, d := range []rune{';', '\t'} {
+		if n := bytes.Count(header, []byte(string(d))); n > most {
+			delimiter, most = d, n
+		}
+	}
+	return delimiter
+}
@@ -0,0 +1,83 @@
+package monitor
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/mmcdole/gofeed"
+)
+
+// typeFeed is the monitor type that follows an RSS, Atom or JSON feed.
+const typeFeed = "feed"
+
+// maxSummaryLength caps the summary of a feed entry in notifications.
+const maxSummaryLength = 200
+
+// feedItems parses an RSS 2.0, Atom or JSON feed and returns its entries,
+// identified by their GUID or ID, falling back to the link. Relative links
+// are resolved against base.
+func feedItems(body io.Reader, base string) ([]Item, error) {
+	feed, err := gofeed.NewParser().Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("feed: parse: %w", err)
+	}
+	baseURL, _ := url.Parse(cmp.Or(feed.Link, base))
+	items := make([]Item, 0, len(feed.Items))
+	for _, entry := range feed.Items {
+		link := entry.Link
+		if link != "" && baseURL != nil {
+			if ref, err := url.Parse(link); err == nil {
+				link = baseURL.ResolveReference(ref).String()
+			}
+		}
+		key := cmp.Or(entry.GUID, link, entry.Title+" "+entry.Published)
+		if strings.TrimSpace(key) == "" {
+			continue
+		}
+		items = append(items, Item{
+			Key:     key,
+			Title:   strings.TrimSpace(entry.Title),
+			Link:    link,
+			Summary: feedSummary(cmp.Or(entry.Description, entry.Content)),
+		})
+	}
+	return items, nil
+}
+
+// feedSummary turns an entry's HTML description into a short line of text.
+func feedSummary(description string) string {
+	text := description
+	if doc, err := goquery.NewDocumentFromReader(strings.NewReader(description)); err == nil {
+		text = doc.Text()
+	}
+	text = strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(text) <= maxSummaryLength {
+		return text
+	}
+	runes := []rune(text)
+	return strings.TrimSpace(string(runes[:maxSummaryLength])) + "…"
+}
+
+// checkFeed records the entries of a feed and notifies about new ones. An
+// entry is only new when neither its ID nor its link was seen before, so
+// feeds that rewrite old entries do not cause repeated notifications. It
+// reports whether a notification was sent.
+func (m *Monitor) checkFeed(resp *Response) (bool, error) {
+	items, err := feedItems(resp.Body, cmp.Or(resp.URL, m.URL))
+	if err != nil {
+		log.Printf("monitor: %v", err)
+		return false, err
+	}
+	added, _, initial := m.diffItems(items, true)
+	if initial {
+		log.Printf("monitor: initial feed entries recorded for %q: %d", m.Name, len(items))
+		return false, nil
+	}
+	return m.notifyItems("entries", added, nil), nil
+}
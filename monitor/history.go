@@ -0,0 +1,118 @@
+package monitor
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+)
+
+// changesKind is the storage suffix for a monitor's recent changes.
+const changesKind = "changes"
+
+// maxChanges is how many changes are kept per monitor.
+const maxChanges = 50
+
+// Change is a notified change, kept so that it can be read later, such as
+// from the feed served by the web server.
+type Change struct {
+	ID      string    `json:"id"`
+	Monitor string    `json:"monitor"`
+	URL     string    `json:"url"`
+	Time    time.Time `json:"time"`
+	Subject string    `json:"subject"`
+	Message string    `json:"message"`
+	// Diff lists removed lines prefixed with "- " and added lines with "+ ",
+	// for changes of the page content.
+	Diff string `json:"diff,omitempty"`
+}
+
+// notify records a change and sends it to the notifiers.
+func (m *Monitor) notify(subject, message, diff string) {
+	now := time.Now()
+	m.recordChange(Change{
+		ID:      fmt.Sprintf("%s-%d", m.id, now.UnixNano()),
+		Monitor: m.Name,
+		URL:     m.URL,
+		Time:    now,
+		Subject: subject,
+		Message: message,
+		Diff:    diff,
+	})
+	if err := m.notifier.Notify(context.Background(), subject, message); err != nil {
+		log.Printf("monitor: notify: %v", err)
+	}
+}
+
+func (m *Monitor) recordChange(c Change) {
+	changes := append(loadChanges(m.storage, m.id), c)
+	if len(changes) > maxChanges {
+		changes = changes[len(changes)-maxChanges:]
+	}
+	data, err := json.Marshal(changes)
+	if err != nil {
+		log.Printf("monitor: encode changes: %v", err)
+		return
+	}
+	m.storage.WriteContent(stateKey(m.id, changesKind), string(data))
+}
+
+func loadChanges(storage Storage, id string) []Change {
+	raw := storage.GetContent(stateKey(id, changesKind))
+	if raw == "" {
+		return nil
+	}
+	var changes []Change
+	if err := json.Unmarshal([]byte(raw), &changes); err != nil {
+		log.Printf("monitor: parse changes: %v", err)
+		return nil
+	}
+	return changes
+}
+
+// lineDiff lists the lines removed from prev and added in next.
+func lineDiff(prev, next string) string {
+	added, removed := lineChanges(prev, next)
+	lines := make([]string, 0, len(added)+len(removed))
+	for _, line := range removed {
+		lines = append(lines, "- "+line)
+	}
+	for _, line := range added {
+		lines = append(lines, "+ "+line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Changes returns the recent changes of the named monitor, or of every
+// monitor when name is empty, newest first.
+func (ms *MonitorService) Changes(name string) ([]Change, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	var changes []Change
+	found := name == ""
+	for i := range ms.monitors {
+		m := &ms.monitors[i]
+		names := []string{m.Name}
+		for _, child := range m.childMonitors() {
+			names = append(names, child.Name)
+		}
+		for _, n := range names {
+			if name != "" && n != name {
+				continue
+			}
+			found = true
+			changes = append(changes, loadChanges(ms.storage, generateSHA1(n))...)
+		}
+	}
+	if !found {
+		return nil, ErrMonitorNotFound
+	}
+	slices.SortFunc(changes, func(a, b Change) int {
+		return cmp.Or(b.Time.Compare(a.Time), strings.Compare(a.Monitor, b.Monitor))
+	})
+	return changes, nil
+}
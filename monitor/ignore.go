@@ -0,0 +1,168 @@
+package monitor
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+)
+
+// IgnoreRules remove dynamic noise, such as timestamps, view counters or CSRF
+// tokens, that would otherwise make every check look like a change.
+type IgnoreRules struct {
+	// Selectors are CSS selectors whose elements are removed from the page
+	// before any content is extracted.
+	Selectors []string `json:"selectors,omitempty"`
+	// Patterns are regular expressions whose matches are removed from the
+	// extracted content.
+	Patterns []string `json:"patterns,omitempty"`
+	// Lines are regular expressions; extracted lines matching any of them are
+	// dropped entirely.
+	Lines []string `json:"lines,omitempty"`
+}
+
+// TextSegment is a piece of extracted content, flagged when ignore rules
+// removed it.
+type TextSegment struct {
+	Text    string `json:"text"`
+	Ignored bool   `json:"ignored,omitempty"`
+}
+
+// stripElements removes the elements matched by the ignore selectors from the
+// HTML in body. It returns the remaining document and the text of what was
+// removed. Only call it for HTML documents: anything else is re-rendered as
+// HTML.
+func (r *IgnoreRules) stripElements(body io.ReadCloser) (io.ReadCloser, []string, error) {
+	if r == nil || len(r.Selectors) == 0 {
+		return body, nil, nil
+	}
+	doc, err := goquery.NewDocumentFromReader(body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ignore: parse html: %w", err)
+	}
+	var removed []string
+	for _, sel := range r.Selectors {
+		matches := doc.Find(sel)
+		matches.Each(func(_ int, s *goquery.Selection) {
+			removed = append(removed, strings.TrimSpace(s.Text()))
+		})
+		matches.Remove()
+	}
+	html, err := doc.Html()
+	if err != nil {
+		return nil, nil, fmt.Errorf("ignore: render html: %w", err)
+	}
+	return io.NopCloser(strings.NewReader(html)), removed, nil
+}
+
+// apply removes ignored lines and patterns from content. Besides the cleaned
+// content it returns the original content split into ignored and kept
+// segments, so that previews can highlight what was removed.
+func (r *IgnoreRules) apply(content string) (string, []TextSegment, error) {
+	if r == nil || len(r.Patterns) == 0 && len(r.Lines) == 0 {
+		return content, nil, nil
+	}
+	patterns, err := compileIgnore(r.Patterns)
+	if err != nil {
+		return "", nil, err
+	}
+	lineRules, err := compileIgnore(r.Lines)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var segments []TextSegment
+	add := func(text string, ignored bool) {
+		if text == "" {
+			return
+		}
+		if n := len(segments); n > 0 && segments[n-1].Ignored == ignored {
+			segments[n-1].Text += text
+			return
+		}
+		segments = append(segments, TextSegment{Text: text, Ignored: ignored})
+	}
+
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		newline := ""
+		if i < len(lines)-1 {
+			newline = "\n"
+		}
+		if slices.ContainsFunc(lineRules, func(re *regexp.Regexp) bool { return re.MatchString(line) }) {
+			add(line+newline, true)
+			continue
+		}
+		pos := 0
+		for _, span := range ignoredSpans(line, patterns) {
+			add(line[pos:span[0]], false)
+			add(line[span[0]:span[1]], true)
+			pos = span[1]
+		}
+		add(line[pos:]+newline, false)
+	}
+
+	var kept strings.Builder
+	for _, s := range segments {
+		if !s.Ignored {
+			kept.WriteString(s.Text)
+		}
+	}
+	return strings.TrimSpace(kept.String()), segments, nil
+}
+
+// ignoredSpans returns the sorted, non-overlapping byte ranges of line
+// matched by any of patterns.
+func ignoredSpans(line string, patterns []*regexp.Regexp) [][2]int {
+	var spans [][2]int
+	for _, re := range patterns {
+		for _, loc := range re.FindAllStringIndex(line, -1) {
+			if loc[0] < loc[1] {
+				spans = append(spans, [2]int{loc[0], loc[1]})
+			}
+		}
+	}
+	slices.SortFunc(spans, func(a, b [2]int) int { return a[0] - b[0] })
+	var merged [][2]int
+	for _, s := range spans {
+		if n := len(merged); n > 0 && s[0] <= merged[n-1][1] {
+			merged[n-1][1] = max(merged[n-1][1], s[1])
+			continue
+		}
+		merged = append(merged, s)
+	}
+	return merged
+}
+
+func compileIgnore(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("ignore: compile %q: %w", p, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// validate checks that every selector and expression compiles.
+func (r *IgnoreRules) validate() error {
+	if r == nil {
+		return nil
+	}
+	for _, sel := range r.Selectors {
+		if _, err := cascadia.Compile(sel); err != nil {
+			return fmt.Errorf("ignore: invalid selector %q: %w", sel, err)
+		}
+	}
+	if _, err := compileIgnore(r.Patterns); err != nil {
+		return err
+	}
+	_, err := compileIgnore(r.Lines)
+	return err
+}
@@ -0,0 +1,386 @@
+package monitor
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"github.com/tidwall/gjson"
+)
+
+// itemsKind is the storage suffix for an item-set monitor's seen items.
+const itemsKind = "items"
+
+// maxSeenItems caps how many items are remembered, so that items which
+// disappeared long ago are eventually forgotten.
+const maxSeenItems = 5000
+
+// ItemSettings turn a monitor into a tracker for a list of items, such as
+// job postings or releases. Every element matched by a CSS selector, or every
+// value matched by a JSON selector (arrays yield one item per element), is an
+// item. Notifications list new items rather than a diff of the page.
+//
+// Key, Title and Link are evaluated on each item: CSS paths relative to the
+// element, which may start with an output suffix alone, such as
+// "::attr(data-id)", to read the element itself; or gjson paths for JSON.
+// Title defaults to the item's text and Link to its first link. Key
+// defaults to the link, then to the title. A JSON value that is not an
+// object, such as a string, is both the key and the title of its item.
+type ItemSettings struct {
+	Key   string `json:"key,omitempty"`
+	Title string `json:"title,omitempty"`
+	Link  string `json:"link,omitempty"`
+	// ReportRemoved also notifies about items that disappeared. Items that
+	// come back after disappearing are never reported as new again.
+	ReportRemoved bool `json:"reportRemoved,omitempty"`
+}
+
+// Item is an entry of an item-set or feed monitor.
+type Item struct {
+	Key   string `json:"key"`
+	Title string `json:"title,omitempty"`
+	Link  string `json:"link,omitempty"`
+	// Summary is a short text of a feed entry. It is not remembered.
+	Summary string `json:"summary,omitempty"`
+}
+
+// seenItem is an item remembered between checks.
+type seenItem struct {
+	Item
+	FirstSeen time.Time `json:"firstSeen"`
+	Present   bool      `json:"present"`
+}
+
+// extract returns the items in body, without duplicate keys. Relative links
+// are resolved against base.
+func (s *ItemSettings) extract(body io.Reader, selector Selector, base string) ([]Item, error) {
+	var (
+		items []Item
+		err   error
+	)
+	switch selector.Type {
+	case "css":
+		items, err = s.extractCSS(body, selector.Paths)
+	case "json":
+		items, err = s.extractJSON(body, selector.Paths)
+	default:
+		return nil, fmt.Errorf("items: need a css or json selector, got %q", selector.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	baseURL, _ := url.Parse(base)
+	seen := make(map[string]bool, len(items))
+	out := items[:0]
+	for _, item := range items {
+		item.Title = strings.Join(strings.Fields(item.Title), " ")
+		if item.Link != "" && baseURL != nil {
+			if ref, err := url.Parse(item.Link); err == nil {
+				item.Link = baseURL.ResolveReference(ref).String()
+			}
+		}
+		if item.Key == "" {
+			item.Key = cmp.Or(item.Link, item.Title)
+		}
+		if item.Key == "" || seen[item.Key] {
+			continue
+		}
+		seen[item.Key] = true
+		out = append(out, item)
+	}
+	return out, nil
+}
+
+func (s *ItemSettings) extractCSS(body io.Reader, paths []string) ([]Item, error) {
+	doc, err := goquery.NewDocumentFromReader(body)
+	if err != nil {
+		return nil, fmt.Errorf("goquery: %w", err)
+	}
+	fields := make([]cssPath, 3)
+	for i, raw := range []string{s.Key, s.Title, cmp.Or(s.Link, "a::attr(href)")} {
+		if raw == "" {
+			continue
+		}
+		if fields[i], err = parseCSSPath(raw); err != nil {
+			return nil, err
+		}
+	}
+	value := func(el *goquery.Selection, p cssPath) string {
+		if p.mode == "" {
+			return ""
+		}
+		if p.selector != "" {
+			el = el.Find(p.selector).First()
+		}
+		if out := p.output(el); len(out) > 0 {
+			return strings.TrimSpace(out[0])
+		}
+		return ""
+	}
+
+	var items []Item
+	for _, p := range paths {
+		doc.Find(p).Each(func(_ int, el *goquery.Selection) {
+			item := Item{
+				Key:   value(el, fields[0]),
+				Title: value(el, fields[1]),
+				Link:  value(el, fields[2]),
+			}
+			if s.Title == "" {
+				item.Title = el.Text()
+			}
+			if s.Link == "" && item.Link == "" && goquery.NodeName(el) == "a" {
+				item.Link, _ = el.Attr("href")
+			}
+			items = append(items, item)
+		})
+	}
+	return items, nil
+}
+
+func (s *ItemSettings) extractJSON(body io.Reader, paths []string) ([]Item, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("json: read body: %w", err)
+	}
+	field := func(v gjson.Result, path, fallback string) string {
+		return v.Get(cmp.Or(path, fallback)).String()
+	}
+	var items []Item
+	add := func(v gjson.Result) {
+		// A value that is not an object, such as a string in an array of
+		// names, is the item's key and title.
+		if !v.IsObject() {
+			if text := v.String(); text != "" {
+				items = append(items, Item{Key: text, Title: text})
+			}
+			return
+		}
+		items = append(items, Item{
+			Key:   field(v, s.Key, "id"),
+			Title: field(v, s.Title, "title"),
+			Link:  field(v, s.Link, "url"),
+		})
+	}
+	for _, p := range paths {
+		v := gjson.GetBytes(data, p)
+		if v.IsArray() {
+			v.ForEach(func(_, e gjson.Result) bool {
+				add(e)
+				return true
+			})
+			continue
+		}
+		if v.Exists() {
+			add(v)
+		}
+	}
+	return items, nil
+}
+
+// validate checks the item fields of a css or json selector.
+func (s *ItemSettings) validate(selector Selector) error {
+	if s == nil {
+		return nil
+	}
+	switch selector.Type {
+	case "json":
+		return nil
+	case "css":
+	default:
+		return fmt.Errorf("items: need a css or json selector, got %q", selector.Type)
+	}
+	for _, raw := range []string{s.Key, s.Title, s.Link} {
+		if raw == "" {
+			continue
+		}
+		p, err := parseCSSPath(raw)
+		if err != nil {
+			return err
+		}
+		if p.selector == "" {
+			continue
+		}
+		if _, err := cascadia.Compile(p.selector); err != nil {
+			return fmt.Errorf("items: invalid selector %q: %w", p.selector, err)
+		}
+	}
+	return nil
+}
+
+// checkItems records the items in a document of the given kind and notifies
+// about new ones, and removed ones when configured. It reports whether a
+// notification was sent.
+func (m *Monitor) checkItems(resp *Response, kind string) (bool, error) {
+	body := resp.Body
+	var err error
+	if kind == docHTML {
+		if body, _, err = m.Ignore.stripElements(body); err != nil {
+			return false, err
+		}
+	}
+	if body, _, err = readablePDF(body, kind); err != nil {
+		return false, err
+	}
+	items, err := m.Items.extract(body, m.Selector, cmp.Or(resp.URL, m.URL))
+	if err != nil {
+		log.Printf("monitor: items: %v", err)
+		return false, err
+	}
+	if len(items) == 0 && m.IgnoreEmpty {
+		log.Print("monitor: no items found, ignoring")
+		return false, nil
+	}
+
+	added, removed, initial := m.diffItems(items, false)
+	if initial {
+		log.Printf("monitor: initial items recorded for %q: %d", m.Name, len(items))
+		return false, nil
+	}
+	if !m.Items.ReportRemoved {
+		removed = nil
+	}
+	return m.notifyItems("items", added, removed), nil
+}
+
+// diffItems remembers the current items and returns those never seen before
+// and those that disappeared since the last check. Items that come back are
+// not new. With byLink, an item whose link was seen under another key is not
+// new either, for feeds that rewrite their entries' IDs. initial reports
+// that no items were remembered yet.
+func (m *Monitor) diffItems(items []Item, byLink bool) (added, removed []Item, initial bool) {
+	seen, initial := m.loadItems()
+	links := make(map[string]bool, len(seen))
+	if byLink {
+		for _, item := range seen {
+			if item.Link != "" {
+				links[item.Link] = true
+			}
+		}
+	}
+
+	now := time.Now()
+	current := make(map[string]bool, len(items))
+	for _, item := range items {
+		current[item.Key] = true
+		stored := Item{Key: item.Key, Title: item.Title, Link: item.Link}
+		prev, ok := seen[item.Key]
+		if !ok {
+			if item.Link == "" || !links[item.Link] {
+				added = append(added, item)
+			}
+			seen[item.Key] = seenItem{Item: stored, FirstSeen: now, Present: true}
+			continue
+		}
+		prev.Item = stored
+		prev.Present = true
+		seen[item.Key] = prev
+	}
+	for key, prev := range seen {
+		if prev.Present && !current[key] {
+			removed = append(removed, prev.Item)
+			prev.Present = false
+			seen[key] = prev
+		}
+	}
+	m.saveItems(seen)
+	return added, removed, initial
+}
+
+// notifyItems notifies about added and removed items, naming them by noun.
+// It reports whether there was anything to notify.
+func (m *Monitor) notifyItems(noun string, added, removed []Item) bool {
+	var counts, lists []string
+	if len(added) > 0 {
+		counts = append(counts, fmt.Sprintf("%d new %s", len(added), noun))
+		lists = append(lists, listItems("New", added))
+	}
+	if len(removed) > 0 {
+		counts = append(counts, fmt.Sprintf("%d removed %s", len(removed), noun))
+		lists = append(lists, listItems("Removed", removed))
+	}
+	if len(counts) == 0 {
+		log.Printf("monitor: no new %s for %q, next check in %s", noun, m.Name, m.Interval*time.Minute)
+		return false
+	}
+
+	summary := strings.Join(counts, ", ")
+	log.Printf("monitor: %q has %s", m.Name, summary)
+	m.notify(
+		fmt.Sprintf("ChangeMonitor: %s – %s", m.Name, summary),
+		fmt.Sprintf("%s\n\n%s", m.URL, strings.Join(lists, "\n\n")),
+		"",
+	)
+	return true
+}
+
+// listItems lists items under a heading, with at most maxListedLines of them.
+func listItems(heading string, items []Item) string {
+	lines := make([]string, 0, len(items))
+	for _, item := range items {
+		line := cmp.Or(item.Title, item.Key)
+		if item.Link != "" && item.Link != line {
+			line += "\n  " + item.Link
+		}
+		if item.Summary != "" {
+			line += "\n  " + item.Summary
+		}
+		lines = append(lines, line)
+	}
+	return heading + ":\n" + listLines("- ", lines)
+}
+
+// itemLines lists items one per line, for previews.
+func itemLines(items []Item) string {
+	lines := make([]string, 0, len(items))
+	for _, item := range items {
+		lines = append(lines, strings.TrimSpace(item.Title+" "+item.Link))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// loadItems returns the remembered items, and whether there were none yet.
+func (m *Monitor) loadItems() (map[string]seenItem, bool) {
+	seen := make(map[string]seenItem)
+	raw := m.storage.GetContent(stateKey(m.id, itemsKind))
+	if raw == "" {
+		return seen, true
+	}
+	if err := json.Unmarshal([]byte(raw), &seen); err != nil {
+		log.Printf("monitor: parse items: %v", err)
+		return make(map[string]seenItem), true
+	}
+	return seen, false
+}
+
+// saveItems stores seen, forgetting the oldest absent items beyond
+// maxSeenItems.
+func (m *Monitor) saveItems(seen map[string]seenItem) {
+	if over := len(seen) - maxSeenItems; over > 0 {
+		var absent []seenItem
+		for _, item := range seen {
+			if !item.Present {
+				absent = append(absent, item)
+			}
+		}
+		slices.SortFunc(absent, func(a, b seenItem) int { return a.FirstSeen.Compare(b.FirstSeen) })
+		for _, item := range absent[:min(over, len(absent))] {
+			delete(seen, item.Key)
+		}
+	}
+	data, err := json.Marshal(seen)
+	if err != nil {
+		log.Printf("monitor: encode items: %v", err)
+		return
+	}
+	m.storage.WriteContent(stateKey(m.id, itemsKind), string(data))
+}
@@ -2,38 +2,109 @@ package monitor
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	"crypto/sha1"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/fetch"
 	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/security"
 	"github.com/chromedp/chromedp"
 	"github.com/tidwall/gjson"
 )
 
+// Request describes a single fetch performed by a MonitorClient.
+type Request struct {
+	URL     string
+	Headers http.Header
+	// Method defaults to GET. Body and ContentType, when set, are sent with
+	// the page request only.
+	Method      string
+	Body        []byte
+	ContentType string
+	// Block lists sub-resources a browser-based client should not load.
+	Block *BlockRules
+	// Jar, when set, supplies cookies for the request and receives the
+	// cookies set in response.
+	Jar *CookieJar
+	// Proxy, when set, is the proxy the request is sent through.
+	Proxy *url.URL
+	// Validators, when set, make the request conditional. Only HTTPClient
+	// sends conditional requests.
+	Validators *Validators
+	// AcceptStatus lists the status codes treated as success, defaulting to
+	// 200 alone. AcceptAnyStatus accepts every status code.
+	AcceptStatus    []int
+	AcceptAnyStatus bool
+	// MaxBodySize is the largest decoded body accepted, in bytes.
+	MaxBodySize int64
+	// TLS, when set, adjusts certificate verification and supplies a client
+	// certificate.
+	TLS *TLSSettings
+	// Waiting, when set, is told why the request is held back before it is
+	// sent, and is called with "" once it goes on.
+	Waiting func(reason string)
+}
+
+// Response is the outcome of a successful fetch. The caller must close Body.
+type Response struct {
+	Body io.ReadCloser
+	// URL is the address the content was finally loaded from, after any
+	// redirects.
+	URL string
+	// NotModified is set when the server confirmed that the content is
+	// unchanged since the request's Validators; Body is then empty.
+	NotModified bool
+	// Validators holds the cache validators sent with the content, if any.
+	Validators *Validators
+	StatusCode int
+	Header     http.Header
+	// TLS describes the connection the content was received over, when the
+	// client knows it.
+	TLS *tls.ConnectionState
+}
+
 // MonitorClient retrieves content from a URL.
 type MonitorClient interface {
-	GetContent(url string, headers http.Header) (io.ReadCloser, error)
+	GetContent(req Request) (*Response, error)
+}
+
+// StatusError reports a response whose HTTP status code was not accepted.
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d", e.Code)
 }
 
 // Storage persists and retrieves recorded content for each monitor.
 type Storage interface {
 	GetContent(id string) string
 	WriteContent(id string, content string)
+	DeleteContent(id string) error
 	// Cleanup removes persisted state for any ID not present in activeIDs.
 	Cleanup(activeIDs []string) error
 }
 
+// ErrMonitorNotFound is returned when no monitor has the requested name.
+var ErrMonitorNotFound = errors.New("monitor not found")
+
 // NotifierService dispatches change notifications.
 type NotifierService interface {
 	Notify(ctx context.Context, subject, message string) error
@@ -41,25 +112,54 @@ type NotifierService interface {
 
 // MonitorService manages a collection of monitors.
 type MonitorService struct {
-	wg           sync.WaitGroup
-	monitors     Monitors
-	httpClient   *HTTPClient
-	chromeClient *ChromeClient
-	storage      Storage
-	notifier     NotifierService
-	chromePath   string
-	chromeWsURL  string
+	wg sync.WaitGroup
+	// mu guards monitors, which Reload replaces while the web server reads
+	// them. reloading serialises reloads.
+	mu                sync.RWMutex
+	reloading         sync.Mutex
+	monitors          Monitors
+	httpClient        *HTTPClient
+	chromeClient      *ChromeClient
+	certificateClient *CertificateClient
+	storage           Storage
+	notifier          NotifierService
+	chromePath        string
+	chromeWsURL       string
+	// settingsMu guards defaults and politeness, which SetDefaults replaces
+	// while checks and previews read them; see settings.
+	settingsMu sync.RWMutex
+	defaults   Defaults
+	politeness *politeness
+}
+
+// Defaults holds settings applied to every monitor that does not configure
+// its own.
+type Defaults struct {
+	Block       *BlockRules    `json:"block,omitempty"`
+	Proxy       *ProxySettings `json:"proxy,omitempty"`
+	MaxBodySize int64          `json:"maxBodySize,omitempty"`
+	Politeness  *Politeness    `json:"politeness,omitempty"`
 }
 
 // HTTPClient fetches page content over plain HTTP.
 type HTTPClient struct {
 	client http.Client
+
+	mu         sync.Mutex
+	transports map[string]*http.Transport
 }
 
 // ChromeClient fetches page content using a headless Chrome browser via chromedp.
 type ChromeClient struct {
 	allocCtx    context.Context
 	cancelAlloc context.CancelFunc
+	remote      bool
+
+	// browserCtx is a local browser kept running to open proxied tabs in,
+	// each in its own browser context. It is started on first use.
+	startBrowser  sync.Once
+	browserCtx    context.Context
+	cancelBrowser context.CancelFunc
 }
 
 // ProductDetection configures automatic stock and price change detection for
@@ -80,23 +180,67 @@ type ProductState struct {
 
 // Monitor describes a single URL to be watched for changes.
 type Monitor struct {
-	Name             string            `json:"name"`
-	URL              string            `json:"url"`
-	HTTPHeaders      http.Header       `json:"httpHeaders,omitempty"`
-	UseChrome        bool              `json:"useChrome"`
-	Interval         time.Duration     `json:"interval"`
-	Selector         Selector          `json:"selector,omitempty"`
-	Filters          *Filters          `json:"filters,omitempty"`
-	IgnoreEmpty      bool              `json:"ignoreEmpty,omitempty"`
-	ProductDetection *ProductDetection `json:"productDetection,omitempty"`
+	Name string `json:"name"`
+	// Type selects what is watched: the page at URL by default, or the TLS
+	// certificate of its host with "certificate".
+	Type             string               `json:"type,omitempty"`
+	URL              string               `json:"url"`
+	HTTPHeaders      http.Header          `json:"httpHeaders,omitempty"`
+	UseChrome        bool                 `json:"useChrome"`
+	Interval         time.Duration        `json:"interval"`
+	Selector         Selector             `json:"selector,omitempty"`
+	Filters          *Filters             `json:"filters,omitempty"`
+	IgnoreEmpty      bool                 `json:"ignoreEmpty,omitempty"`
+	ProductDetection *ProductDetection    `json:"productDetection,omitempty"`
+	Block            *BlockRules          `json:"block,omitempty"`
+	Cookies          *CookieSettings      `json:"cookies,omitempty"`
+	Auth             *Auth                `json:"auth,omitempty"`
+	Method           string               `json:"method,omitempty"`
+	Body             *RequestBody         `json:"body,omitempty"`
+	Proxy            *ProxySettings       `json:"proxy,omitempty"`
+	AcceptStatus     []int                `json:"acceptStatus,omitempty"`
+	TrackResponse    *ResponseTracking    `json:"trackResponse,omitempty"`
+	MaxBodySize      int64                `json:"maxBodySize,omitempty"`
+	TLS              *TLSSettings         `json:"tls,omitempty"`
+	Certificate      *CertificateSettings `json:"certificate,omitempty"`
+	Ignore           *IgnoreRules         `json:"ignore,omitempty"`
+	Normalize        *Normalization       `json:"normalize,omitempty"`
+	Threshold        *Threshold           `json:"threshold,omitempty"`
+	// Trigger is "any" (the default) to notify on every change, "added" to
+	// notify only when lines are added, or "removed" only when lines are
+	// removed.
+	Trigger string `json:"trigger,omitempty"`
+	// Conditions must match for a change to be notified. They apply in
+	// addition to Filters.
+	Conditions *Condition `json:"conditions,omitempty"`
+	// Numeric tracks the first number in the extracted content instead of
+	// the content itself.
+	Numeric *NumericSettings `json:"numeric,omitempty"`
+	// Items tracks the list of items matched by the selector instead of the
+	// page content.
+	Items *ItemSettings `json:"items,omitempty"`
+	// Sitemap configures a monitor of type "sitemap".
+	Sitemap *SitemapSettings `json:"sitemap,omitempty"`
 
 	notifier NotifierService
 	storage  Storage
 	client   MonitorClient
+	auth     *authenticator
+	status   *monitorStatus
+	block    *BlockRules
+	proxy    *ProxySettings
+	maxBody  int64
+	checks   int
 	id       string
-	started  bool
+	started  atomic.Bool
+	// checking is held for the length of a check, so that its state is not
+	// cleared halfway.
+	checking sync.Mutex
 	ticker   *time.Ticker
 	done     chan struct{}
+	children *childMonitors
+	// fingerprint identifies the monitor's settings; see Validators.
+	fingerprint string
 }
 
 // Monitors is a slice of Monitor values.
@@ -106,10 +250,20 @@ type Monitors []Monitor
 type Selector struct {
 	Type  string   `json:"type,omitempty"`
 	Paths []string `json:"paths,omitempty"`
+	// Regex configures the "regex" type, or extracts from the output of any
+	// other type; see RegexOptions.
+	Regex *RegexOptions `json:"regex,omitempty"`
+	// PerMatch puts the text of every element a CSS path matches on its own
+	// line, instead of running it together.
+	PerMatch bool `json:"perMatch,omitempty"`
+	// Steps, when set, replace Type, Paths and Regex with a pipeline of
+	// extraction and transform steps.
+	Steps []Step `json:"steps,omitempty"`
 }
 
 // Filters defines content-based conditions that must match before a notification
-// is sent.
+// is sent: a change is notified when any Contains text is present or any
+// NotContains text is absent. Conditions offer more control.
 type Filters struct {
 	Contains    []string `json:"contains,omitempty"`
 	NotContains []string `json:"notContains,omitempty"`
@@ -119,10 +273,11 @@ type Filters struct {
 // use. Call SetupChrome before Start if any monitor has UseChrome set.
 func NewMonitorService(monitors Monitors, storage Storage, notifier NotifierService) *MonitorService {
 	return &MonitorService{
-		monitors:   monitors,
-		storage:    storage,
-		notifier:   notifier,
-		httpClient: &HTTPClient{client: http.Client{}},
+		monitors:          monitors,
+		storage:           storage,
+		notifier:          notifier,
+		httpClient:        &HTTPClient{client: http.Client{}},
+		certificateClient: &CertificateClient{},
 	}
 }
 
@@ -133,8 +288,10 @@ func NewMonitorService(monitors Monitors, storage Storage, notifier NotifierServ
 func (ms *MonitorService) SetupChrome(chromePath, wsURL string) error {
 	ms.chromePath = chromePath
 	ms.chromeWsURL = wsURL
-	for _, m := range ms.monitors {
-		if !m.UseChrome {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	for i := range ms.monitors {
+		if !ms.monitors[i].needsChrome() {
 			continue
 		}
 		var (
@@ -155,8 +312,30 @@ func (ms *MonitorService) SetupChrome(chromePath, wsURL string) error {
 	return nil
 }
 
+// SetDefaults replaces the settings used by monitors that leave them unset.
+// It takes effect the next time monitors are started or reloaded.
+func (ms *MonitorService) SetDefaults(defaults *Defaults) {
+	if defaults == nil {
+		defaults = &Defaults{}
+	}
+	p := newPoliteness(defaults.Politeness, ms.httpClient)
+	ms.settingsMu.Lock()
+	defer ms.settingsMu.Unlock()
+	ms.defaults = *defaults
+	ms.politeness = p
+}
+
+// settings returns the defaults and the politeness enforcer in effect.
+func (ms *MonitorService) settings() (Defaults, *politeness) {
+	ms.settingsMu.RLock()
+	defer ms.settingsMu.RUnlock()
+	return ms.defaults, ms.politeness
+}
+
 // AddMonitors appends additional monitors to the service.
 func (ms *MonitorService) AddMonitors(monitors ...Monitor) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
 	ms.monitors = append(ms.monitors, monitors...)
 }
 
@@ -164,14 +343,14 @@ func (ms *MonitorService) AddMonitors(monitors ...Monitor) {
 // starts them again. The Chrome client is kept alive across reloads; it is only
 // initialized here if it has not been set up yet.
 func (ms *MonitorService) Reload(monitors Monitors) error {
-	for i := range ms.monitors {
-		if ms.monitors[i].started {
-			ms.monitors[i].Stop()
-		}
-	}
+	ms.reloading.Lock()
+	defer ms.reloading.Unlock()
+	ms.stopAll()
 	ms.wg.Wait()
 
+	ms.mu.Lock()
 	ms.monitors = monitors
+	ms.mu.Unlock()
 	if ms.chromeClient == nil {
 		if err := ms.SetupChrome(ms.chromePath, ms.chromeWsURL); err != nil {
 			return err
@@ -185,14 +364,20 @@ func (ms *MonitorService) Reload(monitors Monitors) error {
 // monitors run in background goroutines. It also cleans up state files for any
 // monitors that are no longer present (e.g. removed manually from the config).
 func (ms *MonitorService) Start() {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
 	activeIDs := make([]string, 0, len(ms.monitors))
-	for i, m := range ms.monitors {
-		id := generateSHA1(m.Name)
+	for i := range ms.monitors {
+		id := generateSHA1(ms.monitors[i].Name)
 		activeIDs = append(activeIDs, id)
 		ms.monitors[i].init(ms)
+		ms.monitors[i].restoreChildren()
 		if err := ms.monitors[i].start(&ms.wg); err != nil {
 			log.Printf("monitor: failed to start %q: %v", ms.monitors[i].Name, err)
 		}
+		for _, child := range ms.monitors[i].childMonitors() {
+			activeIDs = append(activeIDs, child.id)
+		}
 	}
 	if err := ms.storage.Cleanup(activeIDs); err != nil {
 		log.Printf("monitor: cleanup storage: %v", err)
@@ -202,25 +387,86 @@ func (ms *MonitorService) Start() {
 // Shutdown stops all running monitors, waits for them to finish, then closes
 // the Chrome browser if one was started.
 func (ms *MonitorService) Shutdown() {
+	ms.stopAll()
+	ms.wg.Wait()
+	if ms.chromeClient != nil {
+		ms.chromeClient.close()
+	}
+}
+
+// stopAll signals every running monitor to stop.
+func (ms *MonitorService) stopAll() {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
 	for i := range ms.monitors {
-		if ms.monitors[i].started {
+		if ms.monitors[i].started.Load() {
 			ms.monitors[i].Stop()
 		}
 	}
-	ms.wg.Wait()
-	if ms.chromeClient != nil {
-		ms.chromeClient.close()
+}
+
+// ClearCookies deletes the persisted cookie jar of the named monitor, along
+// with any login session. Seed cookies are applied again and a login flow is
+// run again on the next check.
+func (ms *MonitorService) ClearCookies(name string) error {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	m := ms.lookup(name)
+	if m == nil {
+		return ErrMonitorNotFound
+	}
+	// A running check would save its jar again when it ends.
+	m.checking.Lock()
+	defer m.checking.Unlock()
+	id := generateSHA1(name)
+	if err := ms.storage.DeleteContent(stateKey(id, sessionKind)); err != nil {
+		return err
+	}
+	return ms.storage.DeleteContent(stateKey(id, cookiesKind))
+}
+
+// lookup returns the named monitor, which may be a child of a sitemap
+// monitor, or nil. ms.mu must be held.
+func (ms *MonitorService) lookup(name string) *Monitor {
+	for i := range ms.monitors {
+		m := &ms.monitors[i]
+		if m.Name == name {
+			return m
+		}
+		for _, child := range m.childMonitors() {
+			if child.Name == name {
+				return child
+			}
+		}
 	}
+	return nil
 }
 
 // PreviewRequest holds the parameters needed to fetch and process content for a
 // preview without persisting any state.
 type PreviewRequest struct {
-	URL              string            `json:"url"`
-	HTTPHeaders      http.Header       `json:"httpHeaders,omitempty"`
-	UseChrome        bool              `json:"useChrome"`
-	Selector         Selector          `json:"selector"`
-	ProductDetection *ProductDetection `json:"productDetection,omitempty"`
+	Type             string               `json:"type,omitempty"`
+	URL              string               `json:"url"`
+	HTTPHeaders      http.Header          `json:"httpHeaders,omitempty"`
+	UseChrome        bool                 `json:"useChrome"`
+	Selector         Selector             `json:"selector"`
+	ProductDetection *ProductDetection    `json:"productDetection,omitempty"`
+	Block            *BlockRules          `json:"block,omitempty"`
+	Cookies          *CookieSettings      `json:"cookies,omitempty"`
+	Auth             *Auth                `json:"auth,omitempty"`
+	Method           string               `json:"method,omitempty"`
+	Body             *RequestBody         `json:"body,omitempty"`
+	Proxy            *ProxySettings       `json:"proxy,omitempty"`
+	AcceptStatus     []int                `json:"acceptStatus,omitempty"`
+	TrackResponse    *ResponseTracking    `json:"trackResponse,omitempty"`
+	MaxBodySize      int64                `json:"maxBodySize,omitempty"`
+	TLS              *TLSSettings         `json:"tls,omitempty"`
+	Certificate      *CertificateSettings `json:"certificate,omitempty"`
+	Ignore           *IgnoreRules         `json:"ignore,omitempty"`
+	Normalize        *Normalization       `json:"normalize,omitempty"`
+	Numeric          *NumericSettings     `json:"numeric,omitempty"`
+	Items            *ItemSettings        `json:"items,omitempty"`
+	Sitemap          *SitemapSettings     `json:"sitemap,omitempty"`
 }
 
 // PreviewResult holds the outcome of a preview request. Exactly one of Content
@@ -229,43 +475,178 @@ type PreviewRequest struct {
 type PreviewResult struct {
 	Content      string        `json:"content,omitempty"`
 	ProductState *ProductState `json:"productState,omitempty"`
+	// Proxy is the proxy the preview was fetched through, without its
+	// password.
+	Proxy string `json:"proxy,omitempty"`
+	// Steps holds the output of each selector pipeline step.
+	Steps []StepResult `json:"steps,omitempty"`
+	// Highlight is the extracted content before ignore patterns and lines
+	// were removed, with the removed parts flagged. IgnoredElements holds the
+	// text of the elements removed by ignore selectors.
+	Highlight       []TextSegment `json:"highlight,omitempty"`
+	IgnoredElements []string      `json:"ignoredElements,omitempty"`
+	// Raw is the content before normalisation, when normalisation changed it.
+	// Content is then the normalised text that checks compare.
+	Raw string `json:"raw,omitempty"`
+	// Value is the number a numeric monitor would record, when one was found.
+	Value *float64 `json:"value,omitempty"`
+	// Items are the items an item-set monitor would record.
+	Items []Item `json:"items,omitempty"`
+	// ContentType is the media type of the response, which decides how
+	// content is extracted.
+	ContentType string `json:"contentType,omitempty"`
 }
 
 // Preview fetches and processes content for req without recording anything.
 func (ms *MonitorService) Preview(req PreviewRequest) (PreviewResult, error) {
+	defaults, polite := ms.settings()
 	var client MonitorClient
-	if req.UseChrome {
+	switch {
+	case req.Type == typeCertificate:
+		client = ms.certificateClient
+	case req.UseChrome:
 		if ms.chromeClient == nil {
 			return PreviewResult{}, fmt.Errorf("chrome client not initialised")
 		}
-		client = ms.chromeClient
-	} else {
-		client = ms.httpClient
+		client = polite.wrap(ms.chromeClient)
+	default:
+		client = polite.wrap(ms.httpClient)
 	}
 
-	content, err := client.GetContent(req.URL, req.HTTPHeaders)
+	block := req.Block
+	if block == nil {
+		block = defaults.Block
+	}
+	var jar *CookieJar
+	switch {
+	case req.Cookies != nil:
+		jar = newCookieJar(nil, req.Cookies.Seed, req.URL)
+	case req.Auth != nil:
+		jar = newCookieJar(nil, nil, req.URL)
+	}
+	r, err := newRequest(req.URL, req.HTTPHeaders, req.Method, req.Body)
 	if err != nil {
 		return PreviewResult{}, err
 	}
-	defer content.Close()
+	r.Block = block
+	r.Jar = jar
+	r.AcceptStatus = req.AcceptStatus
+	r.AcceptAnyStatus = req.TrackResponse != nil && req.TrackResponse.StatusCode
+	r.MaxBodySize = firstPositive(req.MaxBodySize, defaults.MaxBodySize, defaultMaxBodySize)
+	r.TLS = req.TLS
+	proxy := req.Proxy
+	if proxy == nil {
+		proxy = defaults.Proxy
+	}
+	if r.Proxy, err = proxy.pick(0); err != nil {
+		return PreviewResult{}, err
+	}
+	var resp *Response
+	if auth := newAuthenticator(req.Auth, ms); auth != nil {
+		resp, _, err = auth.fetch(client, r, false)
+	} else {
+		resp, err = client.GetContent(r)
+	}
+	if err != nil {
+		return PreviewResult{}, err
+	}
+	defer resp.Body.Close()
+
+	if req.Type == typeCertificate {
+		report, err := req.Certificate.report(resp, req.TLS, time.Now())
+		if err != nil {
+			return PreviewResult{}, err
+		}
+		text, highlight, err := req.Ignore.apply(report)
+		if err != nil {
+			return PreviewResult{}, err
+		}
+		return PreviewResult{Content: text, Highlight: highlight}, nil
+	}
+
+	kind, contentType, body := detectDocument(resp.Header.Get("Content-Type"), resp.Body)
+	if req.Type == typeFeed {
+		items, err := feedItems(body, cmp.Or(resp.URL, req.URL))
+		if err != nil {
+			return PreviewResult{}, err
+		}
+		return PreviewResult{Content: itemLines(items), Items: items, Proxy: redactedProxy(r.Proxy), ContentType: contentType}, nil
+	}
+	if req.Type == typeSitemap {
+		fetch := func(loc string) (*Response, error) {
+			sub, err := newRequest(loc, req.HTTPHeaders, "", nil)
+			if err != nil {
+				return nil, err
+			}
+			sub.Block, sub.Jar, sub.MaxBodySize, sub.TLS, sub.Proxy = r.Block, r.Jar, r.MaxBodySize, r.TLS, r.Proxy
+			return client.GetContent(sub)
+		}
+		pages, err := readSitemaps(body, cmp.Or(resp.URL, req.URL), fetch, req.Sitemap.maxSitemaps())
+		if err != nil {
+			return PreviewResult{}, err
+		}
+		return PreviewResult{Content: sitemapLines(pages), Proxy: redactedProxy(r.Proxy), ContentType: contentType}, nil
+	}
 
 	if req.ProductDetection != nil && (req.ProductDetection.TrackStock || req.ProductDetection.TrackPrice) {
-		body, err := io.ReadAll(content)
+		data, err := io.ReadAll(body)
 		if err != nil {
 			return PreviewResult{}, fmt.Errorf("preview: read body: %w", err)
 		}
-		ps, err := extractProductData(body)
+		ps, err := extractProductData(data)
 		if err != nil {
 			return PreviewResult{}, err
 		}
-		return PreviewResult{ProductState: ps}, nil
+		return PreviewResult{ProductState: ps, Proxy: redactedProxy(r.Proxy)}, nil
 	}
 
-	text, err := processContent(content, req.Selector)
-	if err != nil {
+	result := PreviewResult{Proxy: redactedProxy(r.Proxy), ContentType: contentType}
+	if kind == docHTML {
+		if body, result.IgnoredElements, err = req.Ignore.stripElements(body); err != nil {
+			return PreviewResult{}, err
+		}
+	}
+	if body, kind, err = readablePDF(body, kind); err != nil {
+		return PreviewResult{}, err
+	}
+
+	if req.Items != nil {
+		if result.Items, err = req.Items.extract(body, req.Selector, cmp.Or(resp.URL, req.URL)); err != nil {
+			return PreviewResult{}, err
+		}
+		result.Content = itemLines(result.Items)
+		return result, nil
+	}
+
+	var text string
+	if len(req.Selector.Steps) > 0 {
+		// A failing step is reported alongside the steps that ran, so the
+		// pipeline can be debugged from the preview.
+		text, result.Steps, err = runPipeline(body, req.Selector.Steps)
+		if err != nil {
+			if len(result.Steps) == 0 {
+				return PreviewResult{}, err
+			}
+			return result, nil
+		}
+		text = strings.TrimSpace(text)
+	} else if text, err = processContent(body, kind, req.Selector); err != nil {
+		return PreviewResult{}, err
+	}
+	if text, result.Highlight, err = req.Ignore.apply(text); err != nil {
 		return PreviewResult{}, err
 	}
-	return PreviewResult{Content: text}, nil
+	if normalized := req.Normalize.apply(text); normalized != text {
+		result.Raw = req.TrackResponse.withMetadata(resp, text)
+		text = normalized
+	}
+	if req.Numeric != nil {
+		if value, err := parseNumber(text); err == nil {
+			result.Value = &value
+		}
+	}
+	result.Content = req.TrackResponse.withMetadata(resp, text)
+	return result, nil
 }
 
 // NewMonitor is a convenience constructor for a basic monitor.
@@ -291,7 +672,7 @@ func (m *Monitor) AddJSONSelectors(selectors ...string) {
 
 // IsRunning reports whether the monitor's polling loop is active.
 func (m *Monitor) IsRunning() bool {
-	return m.started
+	return m.started.Load()
 }
 
 // Stop signals the monitor to stop its polling loop.
@@ -300,34 +681,61 @@ func (m *Monitor) Stop() {
 }
 
 func (m *Monitor) init(ms *MonitorService) {
+	defaults, polite := ms.settings()
 	m.id = generateSHA1(m.Name)
+	if settings, err := json.Marshal(m); err == nil {
+		m.fingerprint = generateSHA1(string(settings))
+	}
 	m.done = make(chan struct{}, 1)
 	m.ticker = time.NewTicker(m.Interval * time.Minute)
 	m.storage = ms.storage
 	m.notifier = ms.notifier
-	if m.UseChrome {
-		m.client = ms.chromeClient
-	} else {
-		m.client = ms.httpClient
+	m.auth = newAuthenticator(m.Auth, ms)
+	m.status = newMonitorStatus(m.Name)
+	m.block = m.Block
+	if m.block == nil {
+		m.block = defaults.Block
+	}
+	m.proxy = m.Proxy
+	if m.proxy == nil {
+		m.proxy = defaults.Proxy
+	}
+	m.maxBody = firstPositive(m.MaxBodySize, defaults.MaxBodySize, defaultMaxBodySize)
+	if m.Type == typeSitemap && m.Sitemap != nil && m.Sitemap.Children != nil {
+		m.children = &childMonitors{start: func(child *Monitor) {
+			child.init(ms)
+			if err := child.start(&ms.wg); err != nil {
+				log.Printf("monitor: failed to start %q: %v", child.Name, err)
+			}
+		}}
+	}
+	switch {
+	case m.Type == typeCertificate:
+		m.client = ms.certificateClient
+	case m.UseChrome:
+		m.client = polite.wrap(ms.chromeClient)
+	default:
+		m.client = polite.wrap(ms.httpClient)
 	}
 }
 
 func (m *Monitor) start(wg *sync.WaitGroup) error {
-	if m.started {
+	if m.started.Load() {
 		return errors.New("monitor is already started")
 	}
 	wg.Add(1)
-	m.started = true
+	m.started.Store(true)
 	go func() {
 		defer func() {
 			wg.Done()
 			m.ticker.Stop()
-			m.started = false
+			m.started.Store(false)
 		}()
 		m.check()
 		for {
 			select {
 			case <-m.done:
+				m.stopAllChildren()
 				return
 			case <-m.ticker.C:
 				m.check()
@@ -344,68 +752,260 @@ func generateSHA1(input string) string {
 }
 
 func (m *Monitor) check() {
+	m.checking.Lock()
+	defer m.checking.Unlock()
 	log.Printf("monitor: checking %s", m.URL)
+	var (
+		checkErr error
+		changed  bool
+	)
+	m.status.begin()
+	defer func() { m.status.finish(checkErr, changed) }()
 
-	content, err := m.client.GetContent(m.URL, m.HTTPHeaders)
+	jar := m.cookieJar()
+	if jar != nil && m.persistCookies() {
+		defer jar.save(m.storage, m.id)
+	}
+	resp, err := m.fetch(jar)
 	if err != nil {
 		log.Printf("monitor: get content: %v", err)
+		checkErr = err
 		return
 	}
-	defer content.Close()
+	defer resp.Body.Close()
+
+	if resp.NotModified {
+		log.Printf("monitor: %s not modified, next check in %s", m.URL, m.Interval*time.Minute)
+		return
+	}
+	// The validators are only kept once the content is recorded, so that a
+	// check that fails is repeated in full instead of answered as not
+	// modified.
+	recorded := false
+	defer func() {
+		if recorded {
+			m.saveValidators(resp.Validators)
+		}
+	}()
+	kind := docHTML
+	if m.Type != typeCertificate {
+		var contentType string
+		kind, contentType, resp.Body = detectDocument(resp.Header.Get("Content-Type"), resp.Body)
+		m.status.update(func(st *Status) { st.ContentType = contentType })
+	}
 
 	if m.ProductDetection != nil && (m.ProductDetection.TrackStock || m.ProductDetection.TrackPrice) {
-		m.checkProduct(content)
+		changed, checkErr = m.checkProduct(resp.Body)
+		recorded = checkErr == nil
+		return
+	}
+	if m.Type == typeFeed {
+		changed, checkErr = m.checkFeed(resp)
+		recorded = checkErr == nil
+		return
+	}
+	if m.Type == typeSitemap {
+		changed, checkErr = m.checkSitemap(resp, jar)
+		recorded = checkErr == nil
+		return
+	}
+	if m.Items != nil {
+		changed, checkErr = m.checkItems(resp, kind)
+		recorded = checkErr == nil
 		return
 	}
 
-	processed, err := processContent(content, m.Selector)
+	processed, err := m.extract(resp, kind)
 	if err != nil {
 		log.Printf("monitor: process content: %v", err)
+		checkErr = err
 		return
 	}
-
-	if m.IgnoreEmpty && processed == "" {
-		log.Print("monitor: content is empty, ignoring")
+	if m.Numeric != nil {
+		changed, checkErr = m.checkNumeric(processed)
+		recorded = checkErr == nil
 		return
 	}
 
-	if m.Filters != nil && !filterMatch(*m.Filters, processed) {
-		log.Print("monitor: no filter matched, ignoring")
+	normalized := m.Normalize.apply(processed)
+	if m.IgnoreEmpty && normalized == "" {
+		log.Print("monitor: content is empty, ignoring")
 		return
 	}
+	raw := m.TrackResponse.withMetadata(resp, processed)
+	normalized = m.TrackResponse.withMetadata(resp, normalized)
 
 	stored := m.storage.GetContent(m.id)
-	if stored == processed {
+	if stored == normalized {
 		log.Printf("monitor: no change detected, next check in %s", m.Interval*time.Minute)
+		recorded = true
+		return
+	}
+
+	previous := m.loadRaw(stored)
+	if ok, err := m.conditionsMet(previous, raw); err != nil {
+		log.Printf("monitor: evaluate conditions: %v", err)
+		checkErr = err
+		return
+	} else if !ok {
+		log.Print("monitor: conditions not met, ignoring")
+		return
+	}
+
+	magnitude := measureChange(stored, normalized)
+	m.status.measured(magnitude)
+	if !m.Threshold.significant(magnitude) {
+		log.Printf("monitor: %q changed below threshold (%s)", m.Name, magnitude)
+		if !m.Threshold.KeepBaseline {
+			m.storage.WriteContent(m.id, normalized)
+			m.saveRaw(raw, normalized)
+			recorded = true
+		}
 		return
 	}
 
-	m.storage.WriteContent(m.id, processed)
-	log.Printf("monitor: %q has changed", m.Name)
-	if err := m.notifier.Notify(
-		context.Background(),
+	m.storage.WriteContent(m.id, normalized)
+	m.saveRaw(raw, normalized)
+	recorded = true
+	if !m.triggered(stored, normalized) {
+		log.Printf("monitor: %q has no %s lines, next check in %s", m.Name, m.Trigger, m.Interval*time.Minute)
+		return
+	}
+	changed = true
+	log.Printf("monitor: %q has changed (%s)", m.Name, magnitude)
+	m.notify(
 		fmt.Sprintf("ChangeMonitor: %s has changed!", m.Name),
-		fmt.Sprintf("%s changed.\n\n---\n(changed) %.200s\n\n(into) %.200s\n---", m.URL, stored, processed),
-	); err != nil {
-		log.Printf("monitor: notify: %v", err)
+		m.changeMessage(previous, raw, magnitude),
+		lineDiff(previous, raw),
+	)
+}
+
+// extract turns a response holding a document of the given kind into the
+// content that is compared between checks, with ignored parts removed.
+func (m *Monitor) extract(resp *Response, kind string) (string, error) {
+	if m.Type == typeCertificate {
+		report, err := m.Certificate.report(resp, m.TLS, time.Now())
+		if err != nil {
+			return "", err
+		}
+		report, _, err = m.Ignore.apply(report)
+		return report, err
+	}
+	body := resp.Body
+	if kind == docHTML {
+		var err error
+		if body, _, err = m.Ignore.stripElements(body); err != nil {
+			return "", err
+		}
+	}
+	processed, err := processContent(body, kind, m.Selector)
+	if err != nil {
+		return "", err
+	}
+	processed, _, err = m.Ignore.apply(processed)
+	return processed, err
+}
+
+// fetch retrieves the monitored page, running the login flow first when the
+// monitor has one and no live session.
+func (m *Monitor) fetch(jar *CookieJar) (*Response, error) {
+	req, err := newRequest(m.URL, m.HTTPHeaders, m.Method, m.Body)
+	if err != nil {
+		return nil, err
+	}
+	req.Block = m.block
+	req.Jar = jar
+	req.Validators = m.loadValidators()
+	req.AcceptStatus = m.AcceptStatus
+	req.AcceptAnyStatus = m.TrackResponse != nil && m.TrackResponse.StatusCode
+	req.MaxBodySize = m.maxBody
+	req.TLS = m.TLS
+	req.Waiting = m.status.waiting
+	if req.Proxy, err = m.proxy.pick(m.checks); err != nil {
+		return nil, err
+	}
+	m.checks++
+	if req.Proxy != nil {
+		log.Printf("monitor: fetching %s through proxy %s", m.URL, redactedProxy(req.Proxy))
 	}
+	return m.send(req)
 }
 
-func (m *Monitor) checkProduct(content io.ReadCloser) {
+// send fetches req, signing in first or again when the monitor's session
+// requires it.
+func (m *Monitor) send(req Request) (*Response, error) {
+	if m.auth == nil {
+		return m.client.GetContent(req)
+	}
+	resp, loggedIn, err := m.auth.fetch(m.client, req, m.hasSession())
+	if loggedIn {
+		m.storage.WriteContent(stateKey(m.id, sessionKind), time.Now().Format(time.RFC3339))
+	}
+	return resp, err
+}
+
+// hasSession reports whether a login session is recorded and still within
+// its maximum age.
+func (m *Monitor) hasSession() bool {
+	raw := m.storage.GetContent(stateKey(m.id, sessionKind))
+	if raw == "" {
+		return false
+	}
+	since, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return false
+	}
+	return m.Auth.SessionMaxAge == 0 || time.Since(since) < m.Auth.SessionMaxAge*time.Minute
+}
+
+// cookieJar returns the jar to use for a check, or nil if the monitor does
+// not need one.
+func (m *Monitor) cookieJar() *CookieJar {
+	var seed []Cookie
+	if m.Cookies != nil {
+		seed = m.Cookies.Seed
+	}
+	switch {
+	case m.persistCookies():
+		return loadCookieJar(m.storage, m.id, seed, m.URL)
+	case m.Cookies != nil || m.Auth != nil:
+		return newCookieJar(nil, seed, m.URL)
+	}
+	return nil
+}
+
+// persistCookies reports whether the monitor's jar is kept between checks.
+// Login sessions are always kept, or every check would sign in again.
+func (m *Monitor) persistCookies() bool {
+	if m.Cookies != nil && m.Cookies.Persist {
+		return true
+	}
+	return m.auth != nil && m.auth.usesSession()
+}
+
+// needsChrome reports whether the monitor uses the Chrome client, either to
+// fetch its page or to sign in.
+func (m *Monitor) needsChrome() bool {
+	return m.UseChrome || m.Auth != nil && m.Auth.Type == "chrome"
+}
+
+// checkProduct compares the product state on the page with the stored one
+// and reports whether a relevant change was found.
+func (m *Monitor) checkProduct(content io.ReadCloser) (bool, error) {
 	body, err := io.ReadAll(content)
 	if err != nil {
 		log.Printf("monitor: product detection: read body: %v", err)
-		return
+		return false, err
 	}
 
 	current, err := extractProductData(body)
 	if err != nil {
 		log.Printf("monitor: product detection: extract: %v", err)
-		return
+		return false, err
 	}
 	if current == nil {
 		log.Printf("monitor: product detection: no product data found on page %s", m.URL)
-		return
+		return false, errors.New("product detection: no product data found")
 	}
 
 	// Load and persist product state.
@@ -423,7 +1023,7 @@ func (m *Monitor) checkProduct(content io.ReadCloser) {
 
 	if stored == nil {
 		log.Printf("monitor: initial product state recorded for %q (inStock=%v price=%.2f)", m.Name, current.InStock, current.Price)
-		return
+		return false, nil
 	}
 
 	pd := m.ProductDetection
@@ -447,18 +1047,17 @@ func (m *Monitor) checkProduct(content io.ReadCloser) {
 
 	if len(changes) == 0 {
 		log.Printf("monitor: no relevant product change for %q, next check in %s", m.Name, m.Interval*time.Minute)
-		return
+		return false, nil
 	}
 
 	changeStr := strings.Join(changes, "; ")
 	log.Printf("monitor: %q product change: %s", m.Name, changeStr)
-	if err := m.notifier.Notify(
-		context.Background(),
+	m.notify(
 		fmt.Sprintf("ChangeMonitor: %s – %s", m.Name, changeStr),
 		fmt.Sprintf("%s\n\n%s\n\nURL: %s", m.Name, changeStr, m.URL),
-	); err != nil {
-		log.Printf("monitor: notify: %v", err)
-	}
+		"",
+	)
+	return true, nil
 }
 
 // extractProductData scans raw HTML for structured product information.
@@ -634,18 +1233,34 @@ func parsePrice(s string) (float64, bool) {
 	return p, err == nil
 }
 
-func processContent(content io.ReadCloser, selector Selector) (string, error) {
-	var (
-		result string
-		err    error
-	)
+// processContent extracts content from a document of the given kind using
+// selector. PDF documents are reduced to their text first.
+func processContent(content io.ReadCloser, kind string, selector Selector) (string, error) {
+	content, kind, err := readablePDF(content, kind)
+	if err != nil {
+		return "", err
+	}
+	if len(selector.Steps) > 0 {
+		result, _, err := runPipeline(content, selector.Steps)
+		return strings.TrimSpace(result), err
+	}
+	var result string
 	switch selector.Type {
 	case "css":
-		result, err = getCSSSelectorContent(content, selector.Paths)
+		result, err = getCSSSelectorContent(content, selector.Paths, selector.PerMatch)
 	case "json":
 		result, err = getJSONSelectorContent(content, selector.Paths)
+	case "xpath":
+		result, err = getXPathSelectorContent(content, selector.Paths, kind == docXML)
+	case "regex":
+		result, err = getRegexSelectorContent(content, selector.Paths, selector.Regex, kind)
+	case "csv":
+		result, err = getCSVSelectorContent(content, selector.Paths)
 	default:
-		result, err = getHTMLText(content)
+		result, err = documentText(content, kind)
+	}
+	if err == nil && selector.Type != "regex" && selector.Regex.chained() {
+		result, err = selector.Regex.extract(result, selector.Regex.Patterns)
 	}
 	if err != nil {
 		return "", err
@@ -653,66 +1268,195 @@ func processContent(content io.ReadCloser, selector Selector) (string, error) {
 	return strings.TrimSpace(result), nil
 }
 
-func filterMatch(filter Filters, content string) bool {
-	for _, f := range filter.Contains {
-		if strings.Contains(content, f) {
-			return true
-		}
-	}
-	for _, f := range filter.NotContains {
-		if !strings.Contains(content, f) {
-			return true
-		}
-	}
-	return false
-}
-
 // GetContent implements MonitorClient for HTTPClient.
-func (h *HTTPClient) GetContent(url string, headers http.Header) (io.ReadCloser, error) {
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+func (h *HTTPClient) GetContent(r Request) (*Response, error) {
+	method := r.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	req, err := http.NewRequest(method, r.URL, bytes.NewReader(r.Body))
 	if err != nil {
 		return nil, fmt.Errorf("http: new request: %w", err)
 	}
-	req.Header = headers
+	// Clone so cookies added by the jar never leak into the monitor's config.
+	req.Header = cloneHeader(r.Headers)
+	if r.ContentType != "" {
+		req.Header.Set("Content-Type", r.ContentType)
+	}
+	if method == http.MethodGet {
+		r.Validators.apply(req.Header)
+	}
+	// Asking for compression explicitly stops net/http from decoding gzip
+	// transparently, so decodeBody handles every encoding the same way.
+	if req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
 
-	resp, err := h.client.Do(req)
+	client, err := h.clientFor(r)
+	if err != nil {
+		return nil, fmt.Errorf("http: %w", err)
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("http: do request: %w", err)
 	}
-	if resp.StatusCode != http.StatusOK {
+	if resp.StatusCode == http.StatusNotModified && !r.Validators.empty() {
+		resp.Body.Close()
+		return &Response{Body: http.NoBody, URL: resp.Request.URL.String(), NotModified: true}, nil
+	}
+	if !r.acceptsStatus(resp.StatusCode) {
+		resp.Body.Close()
+		return nil, fmt.Errorf("http: %w", &StatusError{Code: resp.StatusCode})
+	}
+	body, err := decodeBody(resp, firstPositive(r.MaxBodySize, defaultMaxBodySize))
+	if err != nil {
 		resp.Body.Close()
-		return nil, fmt.Errorf("http: unexpected status %d", resp.StatusCode)
+		return nil, fmt.Errorf("http: decode body: %w", err)
 	}
-	return resp.Body, nil
+	return &Response{
+		Body:       body,
+		URL:        resp.Request.URL.String(),
+		Validators: validatorsFrom(resp),
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		TLS:        resp.TLS,
+	}, nil
+}
+
+// firstPositive returns the first of values that is greater than zero.
+func firstPositive(values ...int64) int64 {
+	for _, v := range values {
+		if v > 0 {
+			return v
+		}
+	}
+	return 0
+}
+
+// cloneHeader returns a deep copy of h that is never nil.
+func cloneHeader(h http.Header) http.Header {
+	if h == nil {
+		return make(http.Header)
+	}
+	return h.Clone()
 }
 
 // GetContent implements MonitorClient for ChromeClient.
-func (c *ChromeClient) GetContent(url string, headers http.Header) (io.ReadCloser, error) {
-	ctx, cancel := chromedp.NewContext(c.allocCtx)
+func (c *ChromeClient) GetContent(req Request) (*Response, error) {
+	if !req.TLS.browserCompatible() {
+		return nil, errors.New("chromedp: custom CA bundles and client certificates are not supported")
+	}
+	ctx, cancel := c.newTab(req.Proxy)
 	defer cancel()
 
+	nav, err := chromedp.RunResponse(ctx, chromeSetup(ctx, req), chromedp.Navigate(req.URL))
+	if err != nil {
+		return nil, fmt.Errorf("chromedp: %w", err)
+	}
+	result := &Response{StatusCode: http.StatusOK, Header: make(http.Header)}
+	if nav != nil {
+		result.StatusCode = int(nav.Status)
+		result.Header = headerFromNetwork(nav.Headers)
+	}
+	// The body is the rendered page, whatever the server sent.
+	result.Header.Set("Content-Type", "text/html; charset=utf-8")
+	// Chrome renders whatever page it gets, so its status is only checked
+	// against an explicit list.
+	if len(req.AcceptStatus) > 0 && !req.acceptsStatus(result.StatusCode) {
+		return nil, fmt.Errorf("chromedp: %w", &StatusError{Code: result.StatusCode})
+	}
+
+	var htmlContent string
+	actions := chromedp.Tasks{
+		chromedp.OuterHTML("html", &htmlContent),
+		chromedp.Location(&result.URL),
+	}
+	actions = append(actions, chromeSyncCookies(req, &result.URL)...)
+	if err := chromedp.Run(ctx, actions); err != nil {
+		return nil, fmt.Errorf("chromedp: %w", err)
+	}
+	if int64(len(htmlContent)) > firstPositive(req.MaxBodySize, defaultMaxBodySize) {
+		return nil, fmt.Errorf("chromedp: %w", ErrBodyTooLarge)
+	}
+	result.Body = io.NopCloser(strings.NewReader(htmlContent))
+	return result, nil
+}
+
+// chromeSetup returns the actions that prepare a fresh tab for req: extra
+// headers, resource blocking and cookies from the request's jar.
+func chromeSetup(ctx context.Context, req Request) chromedp.Tasks {
 	var actions chromedp.Tasks
-	if len(headers) > 0 {
-		networkHeaders := make(network.Headers, len(headers))
-		for k, vals := range headers {
+	if len(req.Headers) > 0 {
+		networkHeaders := make(network.Headers, len(req.Headers))
+		for k, vals := range req.Headers {
 			networkHeaders[k] = strings.Join(vals, ", ")
 		}
 		actions = append(actions, network.SetExtraHTTPHeaders(networkHeaders))
 	}
 
-	var htmlContent string
-	actions = append(actions,
-		chromedp.Navigate(url),
-		chromedp.OuterHTML("html", &htmlContent),
-	)
+	b := req.Block.compile()
+	override := req.overridesNavigation()
+	proxyAuth := req.Proxy != nil && req.Proxy.User != nil
+	if b != nil || override || proxyAuth {
+		var overridden atomic.Bool
+		chromedp.ListenTarget(ctx, func(ev any) {
+			var action chromedp.Action
+			switch ev := ev.(type) {
+			case *fetch.EventRequestPaused:
+				switch {
+				// Chrome normalises the URL it requests, so the page is
+				// recognised as the first document rather than by its URL.
+				case override && ev.ResourceType == network.ResourceTypeDocument && !overridden.Swap(true):
+					action = req.continueWithBody(ev)
+				case b != nil && b.blocks(ev):
+					action = fetch.FailRequest(ev.RequestID, network.ErrorReasonBlockedByClient)
+				default:
+					action = fetch.ContinueRequest(ev.RequestID)
+				}
+			case *fetch.EventAuthRequired:
+				action = proxyCredentials(ev, req.Proxy)
+			default:
+				return
+			}
+			// Responding from the listener itself would deadlock the event
+			// loop, so each paused request is resolved in its own goroutine.
+			go func() {
+				exec := cdp.WithExecutor(ctx, chromedp.FromContext(ctx).Target)
+				if err := action.Do(exec); err != nil && ctx.Err() == nil {
+					log.Printf("chromedp: resolve paused request: %v", err)
+				}
+			}()
+		})
+		actions = append(actions, fetch.Enable().WithHandleAuthRequests(proxyAuth))
+	}
 
-	if err := chromedp.Run(ctx, actions); err != nil {
-		return nil, fmt.Errorf("chromedp: %w", err)
+	if req.TLS != nil && req.TLS.InsecureSkipVerify {
+		actions = append(actions, security.SetIgnoreCertificateErrors(true))
+	}
+	if req.Jar != nil {
+		actions = append(actions, network.SetCookies(req.Jar.cookieParams()))
+	}
+	return actions
+}
+
+// chromeSyncCookies returns the action that copies the tab's cookies back into
+// the request's jar once the page at *finalURL has loaded.
+func chromeSyncCookies(req Request, finalURL *string) chromedp.Tasks {
+	if req.Jar == nil {
+		return nil
+	}
+	return chromedp.Tasks{
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			return req.Jar.syncFromBrowser(ctx, req.Jar.cookieURLs(req.URL, *finalURL))
+		}),
 	}
-	return io.NopCloser(strings.NewReader(htmlContent)), nil
 }
 
 func (c *ChromeClient) close() {
+	c.startBrowser.Do(func() {})
+	if c.cancelBrowser != nil {
+		c.cancelBrowser()
+	}
 	c.cancelAlloc()
 }
 
@@ -726,17 +1470,29 @@ func newLocalChromeClient(chromePath string) (*ChromeClient, error) {
 
 func newRemoteChromeClient(wsURL string) (*ChromeClient, error) {
 	allocCtx, cancelAlloc := chromedp.NewRemoteAllocator(context.Background(), wsURL)
-	return &ChromeClient{allocCtx: allocCtx, cancelAlloc: cancelAlloc}, nil
+	return &ChromeClient{allocCtx: allocCtx, cancelAlloc: cancelAlloc, remote: true}, nil
 }
 
-func getCSSSelectorContent(body io.ReadCloser, selectors []string) (string, error) {
+// getCSSSelectorContent outputs what each path selects; see cssPath. The
+// text of all elements a path matches is run together unless perMatch is
+// set, while other outputs always take a line per element.
+func getCSSSelectorContent(body io.ReadCloser, selectors []string, perMatch bool) (string, error) {
 	doc, err := goquery.NewDocumentFromReader(body)
 	if err != nil {
 		return "", fmt.Errorf("goquery: %w", err)
 	}
 	results := make([]string, 0, len(selectors))
-	for _, sel := range selectors {
-		results = append(results, doc.Find(sel).Text())
+	for _, raw := range selectors {
+		path, err := parseCSSPath(raw)
+		if err != nil {
+			return "", err
+		}
+		sel := doc.Find(path.selector)
+		if path.mode == cssText && !perMatch {
+			results = append(results, sel.Text())
+			continue
+		}
+		results = append(results, strings.Join(path.output(sel), "\n"))
 	}
 	return strings.Join(results, "\n"), nil
 }
@@ -0,0 +1,111 @@
+package monitor
+
+import (
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// rawKind is the storage suffix for the content of the last change before it
+// was normalised.
+const rawKind = "raw"
+
+var (
+	whitespaceRun = regexp.MustCompile(`[\s\p{Zs}]+`)
+	// numberPattern matches a number with the spaces around it.
+	numberPattern = regexp.MustCompile(`[\t\p{Zs}]*\d+(?:[.,\x{a0} ]\d{3})*(?:[.,]\d+)?[\t\p{Zs}]*`)
+	digitPattern  = regexp.MustCompile(`\d`)
+)
+
+// Normalization rewrites extracted content before it is compared, so that
+// changes in formatting alone are not reported. Notifications still show the
+// content as it appeared on the page.
+type Normalization struct {
+	// CollapseWhitespace turns every run of whitespace within a line into a
+	// single space and trims the line.
+	CollapseWhitespace bool `json:"collapseWhitespace,omitempty"`
+	DropBlankLines     bool `json:"dropBlankLines,omitempty"`
+	SortLines          bool `json:"sortLines,omitempty"`
+	// CaseFold ignores differences in letter case.
+	CaseFold bool `json:"caseFold,omitempty"`
+	// NFKC applies Unicode compatibility normalisation, so that for example
+	// full-width letters and ligatures compare equal to their plain forms.
+	NFKC bool `json:"nfkc,omitempty"`
+	// StripDigits removes every digit. StripNumbers removes whole numbers,
+	// including thousands separators and decimals.
+	StripDigits  bool `json:"stripDigits,omitempty"`
+	StripNumbers bool `json:"stripNumbers,omitempty"`
+}
+
+// apply returns content normalised according to n.
+func (n *Normalization) apply(content string) string {
+	if n == nil {
+		return content
+	}
+	if n.NFKC {
+		content = norm.NFKC.String(content)
+	}
+	if n.CaseFold {
+		content = cases.Fold().String(content)
+	}
+	if n.StripNumbers {
+		content = stripNumbers(content)
+	}
+	if n.StripDigits {
+		content = digitPattern.ReplaceAllString(content, "")
+	}
+
+	lines := strings.Split(content, "\n")
+	if n.CollapseWhitespace {
+		for i, line := range lines {
+			lines[i] = strings.TrimSpace(whitespaceRun.ReplaceAllString(line, " "))
+		}
+	}
+	if n.DropBlankLines {
+		lines = slices.DeleteFunc(lines, func(line string) bool { return strings.TrimSpace(line) == "" })
+	}
+	if n.SortLines {
+		slices.Sort(lines)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// stripNumbers removes the numbers in content with the spaces around them,
+// leaving a single space where a number stood between two words, so that
+// "Price 12 kr" and "Price 9 kr" both become "Price kr".
+func stripNumbers(content string) string {
+	return numberPattern.ReplaceAllStringFunc(content, func(match string) string {
+		number := strings.TrimLeftFunc(match, unicode.IsSpace)
+		if len(number) < len(match) && strings.TrimRightFunc(number, unicode.IsSpace) != number {
+			return " "
+		}
+		return ""
+	})
+}
+
+// loadRaw returns the content recorded with stored as it appeared on the
+// page, which is stored itself unless normalisation changed it.
+func (m *Monitor) loadRaw(stored string) string {
+	if raw := m.storage.GetContent(stateKey(m.id, rawKind)); raw != "" && stored != "" {
+		return raw
+	}
+	return stored
+}
+
+// saveRaw records raw alongside its normalised form, or clears the record
+// when normalisation left the content unchanged.
+func (m *Monitor) saveRaw(raw, normalized string) {
+	key := stateKey(m.id, rawKind)
+	if raw == normalized {
+		if err := m.storage.DeleteContent(key); err != nil {
+			log.Printf("monitor: delete raw content: %v", err)
+		}
+		return
+	}
+	m.storage.WriteContent(key, raw)
+}
@@ -0,0 +1,211 @@
+package monitor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// seriesKind is the storage suffix for a numeric monitor's value series.
+const seriesKind = "series"
+
+// defaultHistorySize is how many values a numeric monitor keeps by default.
+const defaultHistorySize = 1000
+
+// numericValue matches a number, with optional sign, thousands separators
+// and decimals. A plain space only separates thousands when exactly three
+// digits follow, so "3 100ml" is read as 3.
+var numericValue = regexp.MustCompile(`[-−]?\d+(?:[.,\x{a0}]\d{3}| \d{3}\b)*(?:[.,]\d+)?`)
+
+// NumericSettings turn a monitor into a tracker for a single number, such as
+// an exchange rate or a queue length, taken from the extracted content.
+// Without any rule every change of the value is notified; otherwise a change
+// is notified when any rule applies.
+type NumericSettings struct {
+	// Above and Below notify when the value changes while above or below
+	// the given value.
+	Above *float64 `json:"above,omitempty"`
+	Below *float64 `json:"below,omitempty"`
+	// Delta and DeltaPercent notify when the value moves by at least this
+	// much, or this many percent, since the last check.
+	Delta        float64 `json:"delta,omitempty"`
+	DeltaPercent float64 `json:"deltaPercent,omitempty"`
+	// Crosses notify when the value moves from one side of a boundary to the
+	// other.
+	Crosses []float64 `json:"crosses,omitempty"`
+	// HistorySize is how many values are kept for charts, defaulting to 1000.
+	HistorySize int `json:"historySize,omitempty"`
+}
+
+// Sample is a value of a numeric monitor at a point in time.
+type Sample struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+}
+
+// parseNumber returns the first number in content. Separators are read the
+// same way as prices, so both "1,299.95" and "1.299,95" are understood,
+// except that commas or dots alone, each followed by exactly three digits,
+// group thousands: "12,500" and "12.500" are both 12500, while "0,125" stays
+// a fraction.
+func parseNumber(content string) (float64, error) {
+	match := numericValue.FindString(content)
+	if match == "" {
+		return 0, fmt.Errorf("numeric: no number in %.50q", content)
+	}
+	negative := strings.HasPrefix(match, "-") || strings.HasPrefix(match, "−")
+	if thousandsOnly(match) {
+		match = strings.NewReplacer(",", "", ".", "").Replace(match)
+	}
+	value, ok := parsePrice(match)
+	if !ok {
+		return 0, fmt.Errorf("numeric: parse %q", match)
+	}
+	if negative {
+		value = -value
+	}
+	return value, nil
+}
+
+// thousandsOnly reports whether number's commas, or its dots, all group
+// thousands of a whole number, as in "12,500" or "1.234.567".
+func thousandsOnly(number string) bool {
+	i := strings.LastIndexAny(number, ",.")
+	if i < 0 || strings.Contains(number, ",") && strings.Contains(number, ".") || len(number)-i-1 != 3 {
+		return false
+	}
+	whole := strings.TrimLeft(number[:strings.IndexAny(number, ",.")], "-−")
+	return strings.Trim(whole, "0 \u00a0") != ""
+}
+
+// alerts returns why a change from prev to next should be notified, or
+// nothing when no rule applies.
+func (n *NumericSettings) alerts(prev, next float64) []string {
+	if n.Above == nil && n.Below == nil && n.Delta == 0 && n.DeltaPercent == 0 && len(n.Crosses) == 0 {
+		return []string{"value changed"}
+	}
+	var reasons []string
+	if n.Above != nil && next > *n.Above {
+		reasons = append(reasons, fmt.Sprintf("above %g", *n.Above))
+	}
+	if n.Below != nil && next < *n.Below {
+		reasons = append(reasons, fmt.Sprintf("below %g", *n.Below))
+	}
+	delta := next - prev
+	if n.Delta > 0 && math.Abs(delta) >= n.Delta {
+		reasons = append(reasons, fmt.Sprintf("moved by %+g", delta))
+	}
+	if n.DeltaPercent > 0 && prev != 0 {
+		if percent := 100 * delta / math.Abs(prev); math.Abs(percent) >= n.DeltaPercent {
+			reasons = append(reasons, fmt.Sprintf("moved by %+.1f%%", percent))
+		}
+	}
+	for _, boundary := range n.Crosses {
+		if (prev < boundary) != (next < boundary) {
+			reasons = append(reasons, fmt.Sprintf("crossed %g", boundary))
+		}
+	}
+	return reasons
+}
+
+// validate checks that the rules make sense.
+func (n *NumericSettings) validate() error {
+	if n == nil {
+		return nil
+	}
+	if n.Delta < 0 || n.DeltaPercent < 0 {
+		return errors.New("numeric: deltas must not be negative")
+	}
+	if n.HistorySize < 0 {
+		return fmt.Errorf("numeric: historySize must not be negative, got %d", n.HistorySize)
+	}
+	return nil
+}
+
+// checkNumeric records the value in content and notifies when one of the
+// monitor's rules applies. It reports whether a notification was sent.
+func (m *Monitor) checkNumeric(content string) (bool, error) {
+	value, err := parseNumber(content)
+	if err != nil {
+		log.Printf("monitor: numeric: %v", err)
+		return false, err
+	}
+
+	series := m.loadSeries()
+	if len(series) > 0 && series[len(series)-1].Value == value {
+		log.Printf("monitor: %q still at %g, next check in %s", m.Name, value, m.Interval*time.Minute)
+		return false, nil
+	}
+	m.saveSeries(append(series, Sample{Time: time.Now(), Value: value}))
+	if len(series) == 0 {
+		log.Printf("monitor: initial value recorded for %q: %g", m.Name, value)
+		return false, nil
+	}
+
+	prev := series[len(series)-1].Value
+	reasons := m.Numeric.alerts(prev, value)
+	if len(reasons) == 0 {
+		log.Printf("monitor: %q moved from %g to %g, no rule applies", m.Name, prev, value)
+		return false, nil
+	}
+
+	summary := fmt.Sprintf("%g → %g (%s)", prev, value, strings.Join(reasons, "; "))
+	log.Printf("monitor: %q numeric change: %s", m.Name, summary)
+	m.notify(
+		fmt.Sprintf("ChangeMonitor: %s – %s", m.Name, summary),
+		fmt.Sprintf("%s\n\n%s\n\nURL: %s", m.Name, summary, m.URL),
+		"",
+	)
+	return true, nil
+}
+
+func (m *Monitor) loadSeries() []Sample {
+	return loadSeries(m.storage, m.id)
+}
+
+// saveSeries stores series, dropping the oldest values beyond the history
+// size.
+func (m *Monitor) saveSeries(series []Sample) {
+	size := m.Numeric.HistorySize
+	if size == 0 {
+		size = defaultHistorySize
+	}
+	if len(series) > size {
+		series = series[len(series)-size:]
+	}
+	data, err := json.Marshal(series)
+	if err != nil {
+		log.Printf("monitor: encode series: %v", err)
+		return
+	}
+	m.storage.WriteContent(stateKey(m.id, seriesKind), string(data))
+}
+
+func loadSeries(storage Storage, id string) []Sample {
+	raw := storage.GetContent(stateKey(id, seriesKind))
+	if raw == "" {
+		return nil
+	}
+	var series []Sample
+	if err := json.Unmarshal([]byte(raw), &series); err != nil {
+		log.Printf("monitor: parse series: %v", err)
+		return nil
+	}
+	return series
+}
+
+// Series returns the recorded values of the named numeric monitor, oldest
+// first.
+func (ms *MonitorService) Series(name string) ([]Sample, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	if ms.lookup(name) == nil {
+		return nil, ErrMonitorNotFound
+	}
+	return loadSeries(ms.storage, generateSHA1(name)), nil
+}
@@ -0,0 +1,97 @@
+package monitor
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		content string
+		want    float64
+	}{
+		{"42", 42},
+		{"Queue: 12,500 jobs", 12500},
+		{"1,234", 1234},
+		{"1.234", 1234},
+		{"0,125", 0.125},
+		{"1.5", 1.5},
+		{"12,50 kr", 12.5},
+		{"1,299.95", 1299.95},
+		{"1.299,95", 1299.95},
+		{"1,234,567", 1234567},
+		{"12 345 678", 12345678},
+		{"1 299,95 kr", 1299.95},
+		{"3 100ml", 3},
+		{"-4.5 °C", -4.5},
+		{"−1,234", -1234},
+		{"In stock: 7 of 10", 7},
+	}
+	for _, tt := range tests {
+		got, err := parseNumber(tt.content)
+		if err != nil {
+			t.Errorf("parseNumber(%q): %v", tt.content, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseNumber(%q) = %g, want %g", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestParseNumberNoNumber(t *testing.T) {
+	for _, content := range []string{"", "sold out", "n/a"} {
+		if _, err := parseNumber(content); err == nil {
+			t.Errorf("parseNumber(%q) succeeded, want an error", content)
+		}
+	}
+}
+
+func TestParsePrice(t *testing.T) {
+	tests := []struct {
+		s    string
+		want float64
+		ok   bool
+	}{
+		{"1,299.95", 1299.95, true},
+		{"1.299,95", 1299.95, true},
+		{"129,95", 129.95, true},
+		{"DKK 1.299,00", 1299, true},
+		{"$19.99", 19.99, true},
+		{"free", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parsePrice(tt.s)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parsePrice(%q) = %g, %t, want %g, %t", tt.s, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestNumericAlerts(t *testing.T) {
+	above, below := 100.0, 10.0
+	tests := []struct {
+		name       string
+		settings   NumericSettings
+		prev, next float64
+		want       []string
+	}{
+		{"no rules", NumericSettings{}, 1, 2, []string{"value changed"}},
+		{"above", NumericSettings{Above: &above}, 90, 120, []string{"above 100"}},
+		{"not above", NumericSettings{Above: &above}, 90, 95, nil},
+		{"below", NumericSettings{Below: &below}, 12, 8, []string{"below 10"}},
+		{"delta", NumericSettings{Delta: 5}, 10, 4, []string{"moved by -6"}},
+		{"small delta", NumericSettings{Delta: 5}, 10, 12, nil},
+		{"delta percent", NumericSettings{DeltaPercent: 10}, 200, 230, []string{"moved by +15.0%"}},
+		{"delta percent from zero", NumericSettings{DeltaPercent: 10}, 0, 5, nil},
+		{"crosses", NumericSettings{Crosses: []float64{50}}, 40, 60, []string{"crossed 50"}},
+		{"crosses down", NumericSettings{Crosses: []float64{50}}, 60, 40, []string{"crossed 50"}},
+		{"stays on one side", NumericSettings{Crosses: []float64{50}}, 60, 70, nil},
+		{"several rules", NumericSettings{Above: &above, Delta: 10}, 95, 110, []string{"above 100", "moved by +15"}},
+	}
+	for _, tt := range tests {
+		if got := tt.settings.alerts(tt.prev, tt.next); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: alerts(%g, %g) = %q, want %q", tt.name, tt.prev, tt.next, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xpath"
+	"github.com/tidwall/gjson"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Step is one stage of a selector pipeline. Each step takes a list of items,
+// starting with the whole response body, and produces a new list:
+//
+//   - "css" and "xpath" select elements in each item, as HTML, using Paths.
+//     CSS paths can output something else with a suffix, as described by
+//     cssPath. XPath expressions that select attributes or text yield their
+//     values.
+//   - "json" evaluates gjson Paths on each item; arrays yield one item per
+//     element.
+//   - "regex" yields every match of the expressions in Paths, formatted as
+//     described by Regex.
+//   - "attribute" reads attribute Name from the first element of each item.
+//   - "html-to-text" replaces markup with its text.
+//   - "replace" replaces matches of Pattern with Replacement, which may
+//     refer to capture groups as $1 or ${name}.
+//   - "trim" trims whitespace and drops empty items.
+//   - "sort", "dedupe" and "lowercase" do what their names say.
+type Step struct {
+	Type        string        `json:"type"`
+	Paths       []string      `json:"paths,omitempty"`
+	Name        string        `json:"name,omitempty"`
+	Regex       *RegexOptions `json:"regex,omitempty"`
+	Pattern     string        `json:"pattern,omitempty"`
+	Replacement string        `json:"replacement,omitempty"`
+}
+
+// StepResult is the output of a pipeline step, for debugging in previews.
+type StepResult struct {
+	Type  string   `json:"type"`
+	Items []string `json:"items"`
+	Error string   `json:"error,omitempty"`
+}
+
+// runPipeline runs steps on body and returns the final items, one per line,
+// together with the output of every step up to the first failing one.
+func runPipeline(body io.Reader, steps []Step) (string, []StepResult, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", nil, fmt.Errorf("pipeline: read body: %w", err)
+	}
+	items := []string{string(data)}
+	results := make([]StepResult, 0, len(steps))
+	for i, step := range steps {
+		if items, err = step.run(items); err != nil {
+			results = append(results, StepResult{Type: step.Type, Error: err.Error()})
+			return "", results, fmt.Errorf("pipeline: step %d (%s): %w", i+1, step.Type, err)
+		}
+		results = append(results, StepResult{Type: step.Type, Items: items})
+	}
+	return strings.Join(items, "\n"), results, nil
+}
+
+func (s Step) run(items []string) ([]string, error) {
+	switch s.Type {
+	case "css":
+		paths := make([]cssPath, 0, len(s.Paths))
+		for _, raw := range s.Paths {
+			path, err := parseCSSPath(raw)
+			if err != nil {
+				return nil, err
+			}
+			// Elements are passed on as markup unless the path asks for
+			// something else.
+			if !path.explicit {
+				path.mode = cssOuterHTML
+			}
+			paths = append(paths, path)
+		}
+		return eachDocument(items, func(doc *goquery.Document) []string {
+			var out []string
+			for _, path := range paths {
+				out = append(out, path.output(doc.Find(path.selector))...)
+			}
+			return out
+		})
+	case "xpath":
+		exprs, err := compileXPaths(s.Paths)
+		if err != nil {
+			return nil, err
+		}
+		var out []string
+		for _, item := range items {
+			nav, err := itemNavigator(item)
+			if err != nil {
+				return nil, err
+			}
+			for _, expr := range exprs {
+				out = append(out, xpathItems(expr, nav.Copy())...)
+			}
+		}
+		return out, nil
+	case "json":
+		var out []string
+		for _, item := range items {
+			for _, p := range s.Paths {
+				v := gjson.Get(item, p)
+				switch {
+				case !v.Exists():
+				case v.IsArray():
+					for _, e := range v.Array() {
+						out = append(out, e.String())
+					}
+				default:
+					out = append(out, v.String())
+				}
+			}
+		}
+		return out, nil
+	case "regex":
+		compiled, err := s.Regex.compile(s.Paths)
+		if err != nil {
+			return nil, err
+		}
+		var out []string
+		for _, item := range items {
+			for _, re := range compiled {
+				out = append(out, s.Regex.matches(re, item)...)
+			}
+		}
+		return out, nil
+	case "attribute":
+		var out []string
+		for _, item := range items {
+			if v, ok := firstElementAttr(item, s.Name); ok {
+				out = append(out, v)
+			}
+		}
+		return out, nil
+	case "html-to-text":
+		return eachDocument(items, func(doc *goquery.Document) []string {
+			doc.Find("script, style").Remove()
+			return []string{doc.Find("body").Text()}
+		})
+	case "replace":
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("replace: compile %q: %w", s.Pattern, err)
+		}
+		return mapItems(items, func(item string) string {
+			return re.ReplaceAllString(item, s.Replacement)
+		}), nil
+	case "trim":
+		out := mapItems(items, strings.TrimSpace)
+		return slices.DeleteFunc(out, func(item string) bool { return item == "" }), nil
+	case "sort":
+		out := slices.Clone(items)
+		slices.Sort(out)
+		return out, nil
+	case "dedupe":
+		seen := make(map[string]bool, len(items))
+		var out []string
+		for _, item := range items {
+			if !seen[item] {
+				seen[item] = true
+				out = append(out, item)
+			}
+		}
+		return out, nil
+	case "lowercase":
+		return mapItems(items, strings.ToLower), nil
+	default:
+		return nil, fmt.Errorf("unknown step type %q", s.Type)
+	}
+}
+
+// eachDocument parses every item as HTML and collects what fn returns.
+func eachDocument(items []string, fn func(*goquery.Document) []string) ([]string, error) {
+	var out []string
+	for _, item := range items {
+		doc, err := parseItem(item)
+		if err != nil {
+			return nil, fmt.Errorf("goquery: %w", err)
+		}
+		out = append(out, fn(goquery.NewDocumentFromNode(doc))...)
+	}
+	return out, nil
+}
+
+// itemNavigator parses an item for XPath, as XML when it declares itself so.
+func itemNavigator(item string) (xpath.NodeNavigator, error) {
+	if isXMLDocument([]byte(item)) {
+		return xmlNavigator([]byte(item))
+	}
+	doc, err := parseItem(item)
+	if err != nil {
+		return nil, fmt.Errorf("xpath: parse html: %w", err)
+	}
+	return htmlquery.CreateXPathNavigator(doc), nil
+}
+
+// parseItem parses an item as an HTML document. A fragment, such as an
+// element passed on by a css step, is parsed inside the element its first tag
+// belongs in, so that rows and cells are not dropped, and then placed in the
+// body of an otherwise empty document.
+func parseItem(item string) (*html.Node, error) {
+	context := fragmentContext(item)
+	if context == nil {
+		return html.Parse(strings.NewReader(item))
+	}
+	nodes, err := html.ParseFragment(strings.NewReader(item), context)
+	if err != nil {
+		return nil, err
+	}
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	for _, n := range nodes {
+		body.AppendChild(n)
+	}
+	root := &html.Node{Type: html.ElementNode, Data: "html", DataAtom: atom.Html}
+	root.AppendChild(&html.Node{Type: html.ElementNode, Data: "head", DataAtom: atom.Head})
+	root.AppendChild(body)
+	doc := &html.Node{Type: html.DocumentNode}
+	doc.AppendChild(root)
+	return doc, nil
+}
+
+// fragmentContext returns the element to parse item in, going by its first
+// tag, or nil when item is a whole document.
+func fragmentContext(item string) *html.Node {
+	parent := atom.Body
+	z := html.NewTokenizer(strings.NewReader(item))
+scan:
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			break scan
+		case html.DoctypeToken:
+			return nil
+		case html.TextToken:
+			if strings.TrimSpace(string(z.Text())) != "" {
+				break scan
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, _ := z.TagName()
+			switch atom.Lookup(name) {
+			case atom.Html, atom.Head, atom.Body, atom.Title, atom.Base, atom.Meta, atom.Link:
+				return nil
+			case atom.Tr:
+				parent = atom.Tbody
+			case atom.Td, atom.Th:
+				parent = atom.Tr
+			case atom.Tbody, atom.Thead, atom.Tfoot, atom.Caption, atom.Colgroup:
+				parent = atom.Table
+			case atom.Col:
+				parent = atom.Colgroup
+			case atom.Option, atom.Optgroup:
+				parent = atom.Select
+			}
+			break scan
+		}
+	}
+	return &html.Node{Type: html.ElementNode, Data: parent.String(), DataAtom: parent}
+}
+
+func mapItems(items []string, fn func(string) string) []string {
+	out := make([]string, len(items))
+	for i, item := range items {
+		out[i] = fn(item)
+	}
+	return out
+}
+
+// firstElementAttr returns attribute name of the first element in the HTML
+// fragment. The fragment is parsed as if inside the element its first tag
+// belongs in, or <body>, so elements such as <meta> or <tr> keep their place.
+func firstElementAttr(fragment, name string) (string, bool) {
+	context := fragmentContext(fragment)
+	if context == nil {
+		context = &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	}
+	nodes, err := html.ParseFragment(strings.NewReader(fragment), context)
+	if err != nil {
+		return "", false
+	}
+	for _, n := range nodes {
+		if n.Type != html.ElementNode {
+			continue
+		}
+		for _, a := range n.Attr {
+			if a.Key == name {
+				return a.Val, true
+			}
+		}
+		return "", false
+	}
+	return "", false
+}
+
+// validate checks a step's configuration without running it.
+func (s Step) validate() error {
+	switch s.Type {
+	case "xpath":
+		_, err := compileXPaths(s.Paths)
+		return err
+	case "regex":
+		_, err := s.Regex.compile(s.Paths)
+		return err
+	case "attribute":
+		if s.Name == "" {
+			return errors.New("attribute step needs a name")
+		}
+	case "replace":
+		if _, err := regexp.Compile(s.Pattern); err != nil {
+			return fmt.Errorf("replace: compile %q: %w", s.Pattern, err)
+		}
+	case "css", "json", "html-to-text", "trim", "sort", "dedupe", "lowercase":
+	default:
+		return fmt.Errorf("unknown step type %q", s.Type)
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/temoto/robotstxt"
+)
+
+const (
+	defaultUserAgent = "ChangeMonitor"
+	// robotsTTL is how long a host's robots.txt is cached.
+	robotsTTL = 24 * time.Hour
+	// robotsMaxSize caps the robots.txt body, as recommended by RFC 9309.
+	robotsMaxSize = 500 << 10
+)
+
+// ErrRobotsDisallowed is returned when a host's robots.txt forbids fetching
+// a monitored page.
+var ErrRobotsDisallowed = errors.New("disallowed by robots.txt")
+
+// Politeness limits how monitors treat the hosts they check. Every setting is
+// applied per host, across all monitors, and all of them are opt-in.
+type Politeness struct {
+	// RespectRobots skips pages that the host's robots.txt disallows for
+	// UserAgent, and honours its Crawl-delay.
+	RespectRobots bool `json:"respectRobots,omitempty"`
+	// UserAgent is the product token matched against robots.txt groups. It
+	// defaults to "ChangeMonitor".
+	UserAgent string `json:"userAgent,omitempty"`
+	// MinDelay is the minimum time between requests to a host, in seconds.
+	MinDelay time.Duration `json:"minDelay,omitempty"`
+	// MaxConcurrent limits how many checks can fetch from a host at once.
+	MaxConcurrent int `json:"maxConcurrent,omitempty"`
+}
+
+func (p *Politeness) enabled() bool {
+	return p != nil && (p.RespectRobots || p.MinDelay > 0 || p.MaxConcurrent > 0)
+}
+
+// politeness enforces Politeness settings for a MonitorService.
+type politeness struct {
+	settings Politeness
+	http     *HTTPClient
+
+	mu    sync.Mutex
+	hosts map[string]*hostState
+}
+
+// hostState is what politeness remembers about a single host.
+type hostState struct {
+	// slots holds a token per running fetch when concurrency is limited.
+	slots chan struct{}
+	// next is the earliest time the next request may start.
+	next time.Time
+
+	robots        *robotstxt.RobotsData
+	robotsFetched time.Time
+}
+
+// newPoliteness returns the enforcer for settings, or nil when none of them
+// are enabled.
+func newPoliteness(settings *Politeness, client *HTTPClient) *politeness {
+	if !settings.enabled() {
+		return nil
+	}
+	return &politeness{settings: *settings, http: client, hosts: make(map[string]*hostState)}
+}
+
+// wrap returns client with politeness applied, or client itself if p is nil.
+func (p *politeness) wrap(client MonitorClient) MonitorClient {
+	if p == nil {
+		return client
+	}
+	return &politeClient{next: client, p: p}
+}
+
+// politeClient is a MonitorClient that waits for the host to be available
+// before passing the request on.
+type politeClient struct {
+	next MonitorClient
+	p    *politeness
+}
+
+// GetContent implements MonitorClient for politeClient. Requests that do not
+// set their own User-Agent identify themselves with the product token that
+// robots.txt is matched against. The host is held until the response body
+// has been read or closed, so downloads count towards MaxConcurrent.
+func (c *politeClient) GetContent(r Request) (*Response, error) {
+	release, err := c.p.acquire(r)
+	if err != nil {
+		return nil, err
+	}
+	if r.Headers.Get("User-Agent") == "" {
+		r.Headers = cloneHeader(r.Headers)
+		r.Headers.Set("User-Agent", c.p.userAgent())
+	}
+	resp, err := c.next.GetContent(r)
+	if err != nil || resp.Body == nil {
+		release()
+		return resp, err
+	}
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// releasingBody gives its host back once it has been read to the end or
+// closed, whichever comes first.
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releasingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		b.once.Do(b.release)
+	}
+	return n, err
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// wait reports that r is being held back.
+func (r Request) wait(reason string) {
+	log.Printf("monitor: %s: %s", r.URL, reason)
+	if r.Waiting != nil {
+		r.Waiting(reason)
+	}
+}
+
+// resume reports that r is no longer held back.
+func (r Request) resume() {
+	if r.Waiting != nil {
+		r.Waiting("")
+	}
+}
+
+func (p *politeness) host(name string) *hostState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	hs, ok := p.hosts[name]
+	if !ok {
+		hs = &hostState{}
+		if p.settings.MaxConcurrent > 0 {
+			hs.slots = make(chan struct{}, p.settings.MaxConcurrent)
+		}
+		p.hosts[name] = hs
+	}
+	return hs
+}
+
+// acquire blocks until r may be sent to its host and returns the function
+// that gives the host back. While it blocks, r.Waiting is told why.
+func (p *politeness) acquire(r Request) (func(), error) {
+	u, err := url.Parse(r.URL)
+	if err != nil {
+		return nil, fmt.Errorf("politeness: parse url: %w", err)
+	}
+	hs := p.host(u.Host)
+
+	delay := p.settings.MinDelay * time.Second
+	if p.settings.RespectRobots {
+		robots, err := p.robots(hs, u, r)
+		if err != nil {
+			return nil, err
+		}
+		group := robots.FindGroup(p.userAgent())
+		if !group.Test(u.RequestURI()) {
+			return nil, fmt.Errorf("politeness: %s: %w", u.Redacted(), ErrRobotsDisallowed)
+		}
+		delay = max(delay, group.CrawlDelay)
+	}
+
+	release := func() {}
+	if hs.slots != nil {
+		select {
+		case hs.slots <- struct{}{}:
+		default:
+			r.wait(fmt.Sprintf("waiting for other checks of %s to finish", u.Host))
+			hs.slots <- struct{}{}
+			r.resume()
+		}
+		release = func() { <-hs.slots }
+	}
+
+	p.mu.Lock()
+	now := time.Now()
+	start := now
+	if hs.next.After(now) {
+		start = hs.next
+	}
+	hs.next = start.Add(delay)
+	p.mu.Unlock()
+	if wait := start.Sub(now); wait > 0 {
+		r.wait(fmt.Sprintf("waiting %s before contacting %s", wait.Round(time.Second), u.Host))
+		time.Sleep(wait)
+		r.resume()
+	}
+	return release, nil
+}
+
+func (p *politeness) userAgent() string {
+	if p.settings.UserAgent != "" {
+		return p.settings.UserAgent
+	}
+	return defaultUserAgent
+}
+
+// robots returns the host's robots.txt, fetching it when the cached copy is
+// missing or stale. A missing robots.txt allows everything, while a server
+// error disallows everything and is retried on the next check.
+func (p *politeness) robots(hs *hostState, u *url.URL, r Request) (*robotstxt.RobotsData, error) {
+	p.mu.Lock()
+	robots, fetched := hs.robots, hs.robotsFetched
+	p.mu.Unlock()
+	if robots != nil && time.Since(fetched) < robotsTTL {
+		return robots, nil
+	}
+
+	robotsURL := (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}).String()
+	req, err := http.NewRequest(http.MethodGet, robotsURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("politeness: new request: %w", err)
+	}
+	req.Header.Set("User-Agent", p.userAgent())
+	client, err := p.http.clientFor(Request{Proxy: r.Proxy, TLS: r.TLS})
+	if err != nil {
+		return nil, fmt.Errorf("politeness: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("politeness: fetch robots.txt: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, robotsMaxSize))
+	if err != nil {
+		return nil, fmt.Errorf("politeness: read robots.txt: %w", err)
+	}
+	if robots, err = robotstxt.FromStatusAndBytes(resp.StatusCode, body); err != nil {
+		return nil, fmt.Errorf("politeness: parse robots.txt: %w", err)
+	}
+
+	if resp.StatusCode < http.StatusInternalServerError {
+		p.mu.Lock()
+		hs.robots, hs.robotsFetched = robots, time.Now()
+		p.mu.Unlock()
+	}
+	return robots, nil
+}
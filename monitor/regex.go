@@ -0,0 +1,117 @@
+package monitor
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// RegexOptions configures regular expression extraction. With the "regex"
+// selector type the expressions are the selector's paths and run on the
+// document text, or on the raw body when Raw is set. With any other type they
+// are listed in Patterns and run on that type's output, so a CSS selector
+// can narrow the page down before a number is picked out of it.
+//
+// Every match becomes one line. Template formats a match using its capture
+// groups, such as "${name} ${version}"; without one a match yields its
+// capture groups separated by spaces, or the whole match when the expression
+// has no groups.
+type RegexOptions struct {
+	Patterns []string `json:"patterns,omitempty"`
+	// Flags are RE2 flags applied to every expression: i (case-insensitive),
+	// m (multi-line), s (dot matches newline) and U (ungreedy).
+	Flags    string `json:"flags,omitempty"`
+	Template string `json:"template,omitempty"`
+	// Separator joins the matches of an expression, defaulting to a newline.
+	Separator string `json:"separator,omitempty"`
+	Raw       bool   `json:"raw,omitempty"`
+}
+
+// compile compiles patterns with the configured flags.
+func (o *RegexOptions) compile(patterns []string) ([]*regexp.Regexp, error) {
+	var flags string
+	if o != nil && o.Flags != "" {
+		for _, f := range o.Flags {
+			if !strings.ContainsRune("imsU", f) {
+				return nil, fmt.Errorf("regex: unknown flag %q", f)
+			}
+		}
+		flags = "(?" + o.Flags + ")"
+	}
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(flags + p)
+		if err != nil {
+			return nil, fmt.Errorf("regex: compile %q: %w", p, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// extract runs patterns on input and returns the formatted matches, one
+// expression after the other.
+func (o *RegexOptions) extract(input string, patterns []string) (string, error) {
+	compiled, err := o.compile(patterns)
+	if err != nil {
+		return "", err
+	}
+	separator := "\n"
+	if o != nil && o.Separator != "" {
+		separator = o.Separator
+	}
+	results := make([]string, 0, len(compiled))
+	for _, re := range compiled {
+		results = append(results, strings.Join(o.matches(re, input), separator))
+	}
+	return strings.Join(results, "\n"), nil
+}
+
+// matches returns every match of re in input, formatted.
+func (o *RegexOptions) matches(re *regexp.Regexp, input string) []string {
+	var matches []string
+	for _, loc := range re.FindAllStringSubmatchIndex(input, -1) {
+		matches = append(matches, o.format(re, input, loc))
+	}
+	return matches
+}
+
+func (o *RegexOptions) format(re *regexp.Regexp, input string, loc []int) string {
+	if o != nil && o.Template != "" {
+		return string(re.ExpandString(nil, o.Template, input, loc))
+	}
+	if re.NumSubexp() == 0 {
+		return input[loc[0]:loc[1]]
+	}
+	groups := make([]string, 0, re.NumSubexp())
+	for i := 1; i <= re.NumSubexp(); i++ {
+		if loc[2*i] >= 0 {
+			groups = append(groups, input[loc[2*i]:loc[2*i+1]])
+		}
+	}
+	return strings.Join(groups, " ")
+}
+
+// chained reports whether o extracts from the output of another selector type.
+func (o *RegexOptions) chained() bool {
+	return o != nil && len(o.Patterns) > 0
+}
+
+func getRegexSelectorContent(body io.ReadCloser, patterns []string, opts *RegexOptions, kind string) (string, error) {
+	var input string
+	if opts != nil && opts.Raw {
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return "", fmt.Errorf("regex: read body: %w", err)
+		}
+		input = string(data)
+	} else {
+		text, err := documentText(body, kind)
+		if err != nil {
+			return "", err
+		}
+		input = text
+	}
+	return opts.extract(input, patterns)
+}
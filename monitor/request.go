@@ -0,0 +1,143 @@
+package monitor
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/chromedp/cdproto/fetch"
+)
+
+// RequestBody is sent along with a monitor's request, which makes it possible
+// to watch search forms, GraphQL endpoints and other APIs that expect a POST.
+//
+// Type selects how the body is built: "raw" sends Content as-is, "json" sends
+// JSON (or Content when JSON is empty) and "form" URL-encodes Form. Content,
+// JSON and the values of Form are Go templates; see templateData for the
+// variables they can use.
+type RequestBody struct {
+	Type        string            `json:"type,omitempty"`
+	Content     string            `json:"content,omitempty"`
+	JSON        json.RawMessage   `json:"json,omitempty"`
+	Form        map[string]string `json:"form,omitempty"`
+	ContentType string            `json:"contentType,omitempty"`
+}
+
+// templateData holds the variables available to request body templates, for
+// example {{.Date}} or {{.Now.Format "02-01-2006"}}.
+type templateData struct {
+	Now  time.Time
+	Date string
+	Time string
+	Unix int64
+}
+
+func newTemplateData(now time.Time) templateData {
+	return templateData{
+		Now:  now,
+		Date: now.Format(time.DateOnly),
+		Time: now.Format(time.TimeOnly),
+		Unix: now.Unix(),
+	}
+}
+
+// newRequest builds the Request for a monitor or preview, rendering body if
+// one is configured. An empty method means GET, or POST when there is a body.
+func newRequest(rawURL string, headers http.Header, method string, body *RequestBody) (Request, error) {
+	req := Request{URL: rawURL, Headers: headers, Method: strings.ToUpper(method)}
+	if body == nil {
+		return req, nil
+	}
+	data, contentType, err := body.render(newTemplateData(time.Now()))
+	if err != nil {
+		return Request{}, err
+	}
+	req.Body = data
+	req.ContentType = contentType
+	if req.Method == "" {
+		req.Method = http.MethodPost
+	}
+	return req, nil
+}
+
+// render executes the body's templates and returns the encoded body with its
+// content type.
+func (b *RequestBody) render(data templateData) ([]byte, string, error) {
+	switch b.Type {
+	case "", "raw":
+		content, err := renderTemplate(b.Content, data)
+		return []byte(content), b.ContentType, err
+	case "json":
+		source := b.Content
+		if len(b.JSON) > 0 {
+			source = string(b.JSON)
+		}
+		content, err := renderTemplate(source, data)
+		return []byte(content), contentTypeOr(b.ContentType, "application/json"), err
+	case "form":
+		form := url.Values{}
+		for k, v := range b.Form {
+			value, err := renderTemplate(v, data)
+			if err != nil {
+				return nil, "", err
+			}
+			form.Set(k, value)
+		}
+		return []byte(form.Encode()), contentTypeOr(b.ContentType, "application/x-www-form-urlencoded"), nil
+	default:
+		return nil, "", fmt.Errorf("request body: unknown type %q", b.Type)
+	}
+}
+
+func renderTemplate(text string, data templateData) (string, error) {
+	tmpl, err := template.New("body").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("request body: parse template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("request body: execute template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+func contentTypeOr(contentType, fallback string) string {
+	if contentType != "" {
+		return contentType
+	}
+	return fallback
+}
+
+// overridesNavigation reports whether Chrome has to rewrite the page request,
+// because it can only navigate with a plain GET.
+func (r Request) overridesNavigation() bool {
+	return r.Method != "" && r.Method != http.MethodGet || len(r.Body) > 0
+}
+
+// continueWithBody resumes the paused page request with r's method and body.
+func (r Request) continueWithBody(paused *fetch.EventRequestPaused) *fetch.ContinueRequestParams {
+	headers := make([]*fetch.HeaderEntry, 0, len(paused.Request.Headers)+1)
+	for name, value := range paused.Request.Headers {
+		if strings.EqualFold(name, "Content-Type") {
+			continue
+		}
+		headers = append(headers, &fetch.HeaderEntry{Name: name, Value: fmt.Sprint(value)})
+	}
+	if r.ContentType != "" {
+		headers = append(headers, &fetch.HeaderEntry{Name: "Content-Type", Value: r.ContentType})
+	}
+	method := r.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	return fetch.ContinueRequest(paused.RequestID).
+		WithMethod(method).
+		WithPostData(base64.StdEncoding.EncodeToString(r.Body)).
+		WithHeaders(headers)
+}
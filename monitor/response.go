@@ -0,0 +1,79 @@
+package monitor
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/chromedp/cdproto/network"
+)
+
+// ResponseTracking adds parts of the HTTP response to a monitor's content, so
+// that they show up in diffs and notifications like any other change. This
+// makes it possible to be alerted when a page starts returning 404 or when a
+// redirect starts pointing somewhere else.
+type ResponseTracking struct {
+	// StatusCode tracks the response status. Every status code is accepted
+	// while it is tracked.
+	StatusCode bool `json:"statusCode,omitempty"`
+	// FinalURL tracks the address the page was loaded from after redirects.
+	FinalURL bool `json:"finalUrl,omitempty"`
+	// Headers lists response headers whose values are tracked.
+	Headers []string `json:"headers,omitempty"`
+}
+
+// acceptsStatus reports whether r accepts a response with the given status.
+// Without an explicit list only 200 is accepted.
+func (r Request) acceptsStatus(code int) bool {
+	if r.AcceptAnyStatus {
+		return true
+	}
+	if len(r.AcceptStatus) == 0 {
+		return code == http.StatusOK
+	}
+	return slices.Contains(r.AcceptStatus, code)
+}
+
+// metadata renders the tracked parts of resp, one per line, ready to be put
+// in front of the processed content. It returns "" when nothing is tracked.
+func (t *ResponseTracking) metadata(resp *Response) string {
+	if t == nil {
+		return ""
+	}
+	var lines []string
+	if t.StatusCode {
+		lines = append(lines, fmt.Sprintf("Status: %d", resp.StatusCode))
+	}
+	if t.FinalURL {
+		lines = append(lines, "URL: "+resp.URL)
+	}
+	for _, name := range t.Headers {
+		lines = append(lines, fmt.Sprintf("%s: %s", http.CanonicalHeaderKey(name), strings.Join(resp.Header.Values(name), ", ")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// withMetadata puts the tracked response metadata in front of content.
+func (t *ResponseTracking) withMetadata(resp *Response, content string) string {
+	meta := t.metadata(resp)
+	switch {
+	case meta == "":
+		return content
+	case content == "":
+		return meta
+	}
+	return meta + "\n\n" + content
+}
+
+// headerFromNetwork converts DevTools response headers into an http.Header.
+func headerFromNetwork(headers network.Headers) http.Header {
+	h := make(http.Header, len(headers))
+	for k, v := range headers {
+		// DevTools joins repeated headers with newlines.
+		for _, value := range strings.Split(fmt.Sprint(v), "\n") {
+			h.Add(k, value)
+		}
+	}
+	return h
+}
@@ -0,0 +1,137 @@
+package monitor
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// Threshold makes a monitor ignore changes that are too small to matter.
+// A change is significant when it meets every configured criterion; changes
+// below the threshold are not notified.
+type Threshold struct {
+	// LinesPercent is the share of lines, from 0 to 100, that must change.
+	LinesPercent float64 `json:"linesPercent,omitempty"`
+	// CharsPercent is the share of characters, from 0 to 100, in changed
+	// lines.
+	CharsPercent float64 `json:"charsPercent,omitempty"`
+	// MinLines is the number of lines that must change. A modified line
+	// counts once.
+	MinLines int `json:"minLines,omitempty"`
+	// MaxSimilarity is the similarity, from 0 to 100, that the new content
+	// must be at or below.
+	MaxSimilarity float64 `json:"maxSimilarity,omitempty"`
+	// KeepBaseline keeps the stored content when a change is below the
+	// threshold, so that small changes add up until they cross it. By default
+	// the stored content is updated silently.
+	KeepBaseline bool `json:"keepBaseline,omitempty"`
+}
+
+// ChangeMagnitude measures how much content changed between two checks.
+type ChangeMagnitude struct {
+	AddedLines   int     `json:"addedLines"`
+	RemovedLines int     `json:"removedLines"`
+	LinesPercent float64 `json:"linesPercent"`
+	CharsPercent float64 `json:"charsPercent"`
+	// Similarity compares the character pairs of both versions, from 0 for
+	// nothing in common to 100 for identical content.
+	Similarity float64 `json:"similarity"`
+}
+
+// changedLines counts a modified line, which is both added and removed, once.
+func (c ChangeMagnitude) changedLines() int {
+	return max(c.AddedLines, c.RemovedLines)
+}
+
+func (c ChangeMagnitude) String() string {
+	return fmt.Sprintf("%d lines added, %d removed; %.1f%% of lines, %.1f%% of characters; %.1f%% similar",
+		c.AddedLines, c.RemovedLines, c.LinesPercent, c.CharsPercent, c.Similarity)
+}
+
+// measureChange compares two versions of content.
+func measureChange(prev, next string) ChangeMagnitude {
+	added, removed := lineChanges(prev, next)
+	c := ChangeMagnitude{
+		AddedLines:   len(added),
+		RemovedLines: len(removed),
+		Similarity:   similarity(prev, next),
+	}
+	if lines := max(len(splitLines(prev)), len(splitLines(next))); lines > 0 {
+		c.LinesPercent = 100 * float64(c.changedLines()) / float64(lines)
+	}
+	if chars := lineChars(splitLines(prev)) + lineChars(splitLines(next)); chars > 0 {
+		c.CharsPercent = 100 * float64(lineChars(added)+lineChars(removed)) / float64(chars)
+	}
+	return c
+}
+
+// lineChars counts the characters in lines, not counting line breaks.
+func lineChars(lines []string) int {
+	n := 0
+	for _, line := range lines {
+		n += utf8.RuneCountInString(line)
+	}
+	return n
+}
+
+// similarity returns the Sørensen–Dice coefficient of the character pairs in
+// a and b as a percentage.
+func similarity(a, b string) float64 {
+	if a == b {
+		return 100
+	}
+	pairs := func(s string) map[[2]rune]int {
+		counts := make(map[[2]rune]int)
+		runes := []rune(s)
+		for i := 0; i+1 < len(runes); i++ {
+			counts[[2]rune{runes[i], runes[i+1]}]++
+		}
+		return counts
+	}
+	pa, pb := pairs(a), pairs(b)
+	total := 0
+	for _, n := range pa {
+		total += n
+	}
+	for _, n := range pb {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+	shared := 0
+	for p, n := range pa {
+		shared += min(n, pb[p])
+	}
+	return 100 * float64(2*shared) / float64(total)
+}
+
+// significant reports whether a change of magnitude c crosses the threshold.
+// Without a threshold every change is significant.
+func (t *Threshold) significant(c ChangeMagnitude) bool {
+	if t == nil {
+		return true
+	}
+	return c.LinesPercent >= t.LinesPercent &&
+		c.CharsPercent >= t.CharsPercent &&
+		c.changedLines() >= t.MinLines &&
+		(t.MaxSimilarity == 0 || c.Similarity <= t.MaxSimilarity)
+}
+
+// validate checks that percentages are in range.
+func (t *Threshold) validate() error {
+	if t == nil {
+		return nil
+	}
+	for _, p := range []struct {
+		name  string
+		value float64
+	}{{"linesPercent", t.LinesPercent}, {"charsPercent", t.CharsPercent}, {"maxSimilarity", t.MaxSimilarity}} {
+		if p.value < 0 || p.value > 100 {
+			return fmt.Errorf("threshold: %s must be between 0 and 100, got %g", p.name, p.value)
+		}
+	}
+	if t.MinLines < 0 {
+		return fmt.Errorf("threshold: minLines must not be negative, got %d", t.MinLines)
+	}
+	return nil
+}
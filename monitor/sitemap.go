@@ -0,0 +1,491 @@
+package monitor
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+// typeSitemap is the monitor type that follows the pages listed in a
+// sitemap.
+const typeSitemap = "sitemap"
+
+// Storage suffixes for a sitemap monitor's listed pages and the URLs of its
+// child monitors.
+const (
+	sitemapKind  = "sitemap"
+	childrenKind = "children"
+)
+
+// Defaults for SitemapSettings.
+const (
+	defaultMaxSitemaps = 50
+	defaultMaxChildren = 20
+)
+
+// SitemapSettings configures a sitemap monitor, which notifies about pages
+// that are added to, removed from or updated in a sitemap.
+type SitemapSettings struct {
+	// MaxSitemaps caps how many sitemaps are read when the URL is a sitemap
+	// index; 50 by default.
+	MaxSitemaps int `json:"maxSitemaps,omitempty"`
+	// IgnoreUpdated skips pages whose lastmod changed.
+	IgnoreUpdated bool `json:"ignoreUpdated,omitempty"`
+	// Children, when set, watches new pages that match its pattern with
+	// monitors of their own.
+	Children *SitemapChildren `json:"children,omitempty"`
+}
+
+// SitemapChildren describes the content monitors created for new pages in a
+// sitemap. They are named after the sitemap monitor and the page URL, share
+// its request settings, and stop when the page leaves the sitemap.
+type SitemapChildren struct {
+	// Pattern is a regular expression a page URL must match.
+	Pattern string `json:"pattern"`
+	// Interval between checks in minutes; the sitemap monitor's by default.
+	Interval time.Duration `json:"interval,omitempty"`
+	Selector Selector      `json:"selector,omitempty"`
+	// Max caps how many child monitors run at once; 20 by default.
+	Max int `json:"max,omitempty"`
+}
+
+// childMonitors holds the running child monitors of a sitemap monitor. The
+// list is changed by the sitemap monitor's goroutine and read by the web
+// server.
+type childMonitors struct {
+	mu       sync.Mutex
+	monitors []*Monitor
+	// start initialises and starts a child monitor.
+	start func(*Monitor)
+}
+
+// sitemapDocument is an XML sitemap: a <urlset> listing pages or a
+// <sitemapindex> listing further sitemaps.
+type sitemapDocument struct {
+	XMLName  xml.Name
+	URLs     []sitemapEntry `xml:"url"`
+	Sitemaps []sitemapEntry `xml:"sitemap"`
+}
+
+type sitemapEntry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// sitemapFetcher retrieves a sitemap listed in a sitemap index.
+type sitemapFetcher func(loc string) (*Response, error)
+
+// readSitemaps returns the pages listed in the sitemap in body, mapped to
+// their lastmod, following a sitemap index to at most limit sitemaps. Every
+// sitemap may be gzip-compressed, and may be a plain list of URLs instead of
+// XML. Locations are resolved against the sitemap that lists them.
+func readSitemaps(body io.Reader, base string, fetch sitemapFetcher, limit int) (map[string]string, error) {
+	pages := make(map[string]string)
+	visited := map[string]bool{base: true}
+	var queue []string
+	for read := 1; ; read++ {
+		doc, err := parseSitemap(body)
+		if err != nil {
+			return nil, fmt.Errorf("sitemap: %s: %w", base, err)
+		}
+		for _, entry := range doc.URLs {
+			if loc := resolveLoc(base, entry.Loc); loc != "" {
+				pages[loc] = strings.TrimSpace(entry.LastMod)
+			}
+		}
+		for _, entry := range doc.Sitemaps {
+			if loc := resolveLoc(base, entry.Loc); loc != "" && !visited[loc] {
+				visited[loc] = true
+				queue = append(queue, loc)
+			}
+		}
+
+		if len(queue) == 0 {
+			return pages, nil
+		}
+		if read == limit {
+			log.Printf("monitor: sitemap: read %d sitemaps, skipping %d more", read, len(queue))
+			return pages, nil
+		}
+		base, queue = queue[0], queue[1:]
+		resp, err := fetch(base)
+		if err != nil {
+			return nil, fmt.Errorf("sitemap: %s: %w", base, err)
+		}
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("sitemap: %s: read body: %w", base, err)
+		}
+		body = bytes.NewReader(data)
+	}
+}
+
+// parseSitemap reads a single sitemap, unpacking it first if it is
+// gzip-compressed.
+func parseSitemap(body io.Reader) (sitemapDocument, error) {
+	buffered := bufio.NewReader(body)
+	if magic, _ := buffered.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return sitemapDocument{}, fmt.Errorf("gunzip: %w", err)
+		}
+		defer gz.Close()
+		buffered = bufio.NewReader(gz)
+	}
+	data, err := io.ReadAll(buffered)
+	if err != nil {
+		return sitemapDocument{}, fmt.Errorf("read: %w", err)
+	}
+
+	var doc sitemapDocument
+	trimmed := bytes.TrimSpace(data)
+	if !bytes.HasPrefix(trimmed, []byte("<")) {
+		for line := range strings.Lines(string(trimmed)) {
+			if line = strings.TrimSpace(line); line != "" {
+				doc.URLs = append(doc.URLs, sitemapEntry{Loc: line})
+			}
+		}
+		return doc, nil
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(trimmed))
+	decoder.CharsetReader = charset.NewReaderLabel
+	if err := decoder.Decode(&doc); err != nil {
+		return sitemapDocument{}, fmt.Errorf("parse: %w", err)
+	}
+	switch doc.XMLName.Local {
+	case "urlset", "sitemapindex":
+		return doc, nil
+	default:
+		return sitemapDocument{}, fmt.Errorf("parse: unexpected root element <%s>", doc.XMLName.Local)
+	}
+}
+
+// resolveLoc resolves a sitemap location against base, returning "" for
+// anything that is not an http or https URL.
+func resolveLoc(base, loc string) string {
+	ref, err := url.Parse(strings.TrimSpace(loc))
+	if err != nil || loc == "" {
+		return ""
+	}
+	if baseURL, err := url.Parse(base); err == nil {
+		ref = baseURL.ResolveReference(ref)
+	}
+	if ref.Scheme != "http" && ref.Scheme != "https" {
+		return ""
+	}
+	return ref.String()
+}
+
+// sitemapLines lists pages one per line, sorted, with their lastmod.
+func sitemapLines(pages map[string]string) string {
+	lines := make([]string, 0, len(pages))
+	for _, loc := range slices.Sorted(maps.Keys(pages)) {
+		lines = append(lines, strings.TrimSpace(loc+"  "+pages[loc]))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (s *SitemapSettings) maxSitemaps() int {
+	if s == nil || s.MaxSitemaps <= 0 {
+		return defaultMaxSitemaps
+	}
+	return s.MaxSitemaps
+}
+
+func (s *SitemapSettings) validate() error {
+	if s == nil {
+		return nil
+	}
+	if s.MaxSitemaps < 0 {
+		return errors.New("sitemap: maxSitemaps must not be negative")
+	}
+	if s.Children == nil {
+		return nil
+	}
+	if _, err := regexp.Compile(s.Children.Pattern); err != nil {
+		return fmt.Errorf("sitemap: children: invalid pattern: %w", err)
+	}
+	if s.Children.Interval < 0 || s.Children.Max < 0 {
+		return errors.New("sitemap: children: interval and max must not be negative")
+	}
+	if err := s.Children.Selector.validate(); err != nil {
+		return fmt.Errorf("sitemap: children: %w", err)
+	}
+	return nil
+}
+
+// checkSitemap records the pages listed in the sitemap and notifies about
+// added, removed and updated ones. It reports whether a notification was
+// sent.
+func (m *Monitor) checkSitemap(resp *Response, jar *CookieJar) (bool, error) {
+	fetch := func(loc string) (*Response, error) { return m.fetchSitemap(loc, jar) }
+	pages, err := readSitemaps(resp.Body, cmp.Or(resp.URL, m.URL), fetch, m.Sitemap.maxSitemaps())
+	if err != nil {
+		log.Printf("monitor: %v", err)
+		return false, err
+	}
+	if len(pages) == 0 && m.IgnoreEmpty {
+		log.Print("monitor: sitemap lists no pages, ignoring")
+		return false, nil
+	}
+
+	stored, initial := m.loadSitemap()
+	m.saveSitemap(pages)
+	if initial {
+		log.Printf("monitor: initial sitemap recorded for %q: %d pages", m.Name, len(pages))
+		return false, nil
+	}
+	var added, removed, updated []string
+	for loc, lastmod := range pages {
+		prev, ok := stored[loc]
+		switch {
+		case !ok:
+			added = append(added, loc)
+		case prev != "" && lastmod != "" && prev != lastmod:
+			updated = append(updated, loc)
+		}
+	}
+	for loc := range stored {
+		if _, ok := pages[loc]; !ok {
+			removed = append(removed, loc)
+		}
+	}
+	slices.Sort(added)
+	slices.Sort(removed)
+	slices.Sort(updated)
+	if m.Sitemap != nil && m.Sitemap.IgnoreUpdated {
+		updated = nil
+	}
+	m.startChildren(added)
+	m.stopChildren(removed)
+
+	var counts, lists, diff []string
+	if len(added) > 0 {
+		counts = append(counts, fmt.Sprintf("%d new URLs", len(added)))
+		lists = append(lists, "New:\n"+listLines("- ", added))
+	}
+	if len(removed) > 0 {
+		counts = append(counts, fmt.Sprintf("%d removed URLs", len(removed)))
+		lists = append(lists, "Removed:\n"+listLines("- ", removed))
+	}
+	if len(updated) > 0 {
+		lines := make([]string, 0, len(updated))
+		for _, loc := range updated {
+			lines = append(lines, fmt.Sprintf("%s (%s → %s)", loc, stored[loc], pages[loc]))
+		}
+		counts = append(counts, fmt.Sprintf("%d updated URLs", len(updated)))
+		lists = append(lists, "Updated:\n"+listLines("- ", lines))
+	}
+	if len(counts) == 0 {
+		log.Printf("monitor: no sitemap changes for %q, next check in %s", m.Name, m.Interval*time.Minute)
+		return false, nil
+	}
+	for _, loc := range removed {
+		diff = append(diff, "- "+loc)
+	}
+	for _, loc := range added {
+		diff = append(diff, "+ "+loc)
+	}
+
+	summary := strings.Join(counts, ", ")
+	log.Printf("monitor: %q has %s", m.Name, summary)
+	m.notify(
+		fmt.Sprintf("ChangeMonitor: %s – %s", m.Name, summary),
+		fmt.Sprintf("%s\n\n%s", m.URL, strings.Join(lists, "\n\n")),
+		strings.Join(diff, "\n"),
+	)
+	return true, nil
+}
+
+// fetchSitemap fetches a sitemap listed in the monitor's sitemap index with
+// the monitor's request settings, cookie jar and login.
+func (m *Monitor) fetchSitemap(loc string, jar *CookieJar) (*Response, error) {
+	req, err := newRequest(loc, m.HTTPHeaders, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Block = m.block
+	req.Jar = jar
+	req.MaxBodySize = m.maxBody
+	req.TLS = m.TLS
+	req.Waiting = m.status.waiting
+	if req.Proxy, err = m.proxy.pick(m.checks); err != nil {
+		return nil, err
+	}
+	return m.send(req)
+}
+
+// loadSitemap returns the pages recorded by the last check, and whether
+// there were none yet.
+func (m *Monitor) loadSitemap() (map[string]string, bool) {
+	pages := make(map[string]string)
+	raw := m.storage.GetContent(stateKey(m.id, sitemapKind))
+	if raw == "" {
+		return pages, true
+	}
+	if err := json.Unmarshal([]byte(raw), &pages); err != nil {
+		log.Printf("monitor: parse sitemap: %v", err)
+		return make(map[string]string), true
+	}
+	return pages, false
+}
+
+func (m *Monitor) saveSitemap(pages map[string]string) {
+	data, err := json.Marshal(pages)
+	if err != nil {
+		log.Printf("monitor: encode sitemap: %v", err)
+		return
+	}
+	m.storage.WriteContent(stateKey(m.id, sitemapKind), string(data))
+}
+
+// childMonitor returns the monitor that watches the page at loc.
+func (m *Monitor) childMonitor(loc string) *Monitor {
+	settings := m.Sitemap.Children
+	return &Monitor{
+		Name:         fmt.Sprintf("%s: %s", m.Name, loc),
+		URL:          loc,
+		HTTPHeaders:  m.HTTPHeaders,
+		UseChrome:    m.UseChrome,
+		Interval:     cmp.Or(settings.Interval, m.Interval),
+		Selector:     settings.Selector,
+		IgnoreEmpty:  m.IgnoreEmpty,
+		Block:        m.Block,
+		Cookies:      m.Cookies,
+		Auth:         m.Auth,
+		Proxy:        m.Proxy,
+		AcceptStatus: m.AcceptStatus,
+		MaxBodySize:  m.MaxBodySize,
+		TLS:          m.TLS,
+	}
+}
+
+// restoreChildren starts the child monitors recorded by earlier checks that
+// still match the pattern.
+func (m *Monitor) restoreChildren() {
+	if m.children == nil {
+		return
+	}
+	var locs []string
+	if raw := m.storage.GetContent(stateKey(m.id, childrenKind)); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &locs); err != nil {
+			log.Printf("monitor: parse children: %v", err)
+		}
+	}
+	m.startChildren(locs)
+}
+
+// startChildren starts a child monitor for every page in locs that matches
+// the pattern, up to the maximum, and records them.
+func (m *Monitor) startChildren(locs []string) {
+	if m.children == nil || len(locs) == 0 {
+		return
+	}
+	settings := m.Sitemap.Children
+	pattern := regexp.MustCompile(settings.Pattern)
+	limit := cmp.Or(settings.Max, defaultMaxChildren)
+
+	m.children.mu.Lock()
+	running := make(map[string]bool, len(m.children.monitors))
+	for _, child := range m.children.monitors {
+		running[child.URL] = true
+	}
+	var started []*Monitor
+	for _, loc := range locs {
+		if running[loc] || !pattern.MatchString(loc) {
+			continue
+		}
+		if len(m.children.monitors) >= limit {
+			log.Printf("monitor: %q already has %d child monitors, not watching %s", m.Name, limit, loc)
+			continue
+		}
+		child := m.childMonitor(loc)
+		running[loc] = true
+		m.children.monitors = append(m.children.monitors, child)
+		started = append(started, child)
+	}
+	m.children.mu.Unlock()
+
+	for _, child := range started {
+		log.Printf("monitor: %q is watching %s", m.Name, child.URL)
+		m.children.start(child)
+	}
+	if len(started) > 0 {
+		m.saveChildren()
+	}
+}
+
+// stopChildren stops and forgets the child monitors of the pages in locs.
+func (m *Monitor) stopChildren(locs []string) {
+	if m.children == nil || len(locs) == 0 {
+		return
+	}
+	m.children.mu.Lock()
+	before := len(m.children.monitors)
+	m.children.monitors = slices.DeleteFunc(m.children.monitors, func(child *Monitor) bool {
+		if !slices.Contains(locs, child.URL) {
+			return false
+		}
+		if child.started.Load() {
+			child.Stop()
+		}
+		log.Printf("monitor: %q stopped watching %s", m.Name, child.URL)
+		return true
+	})
+	changed := len(m.children.monitors) != before
+	m.children.mu.Unlock()
+	if changed {
+		m.saveChildren()
+	}
+}
+
+// stopAllChildren stops the child monitors without forgetting them, for when
+// the sitemap monitor itself stops.
+func (m *Monitor) stopAllChildren() {
+	for _, child := range m.childMonitors() {
+		if child.started.Load() {
+			child.Stop()
+		}
+	}
+}
+
+func (m *Monitor) saveChildren() {
+	children := m.childMonitors()
+	locs := make([]string, 0, len(children))
+	for _, child := range children {
+		locs = append(locs, child.URL)
+	}
+	data, err := json.Marshal(locs)
+	if err != nil {
+		log.Printf("monitor: encode children: %v", err)
+		return
+	}
+	m.storage.WriteContent(stateKey(m.id, childrenKind), string(data))
+}
+
+// childMonitors returns the running child monitors of a sitemap monitor.
+func (m *Monitor) childMonitors() []*Monitor {
+	if m.children == nil {
+		return nil
+	}
+	m.children.mu.Lock()
+	defer m.children.mu.Unlock()
+	return slices.Clone(m.children.monitors)
+}
@@ -0,0 +1,118 @@
+package monitor
+
+import (
+	"sync"
+	"time"
+)
+
+// Monitor states reported in Status.
+const (
+	StateIdle     = "idle"
+	StateChecking = "checking"
+	StateWaiting  = "waiting"
+	StateError    = "error"
+)
+
+// Status is a snapshot of what a monitor is doing and how its last check went.
+type Status struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+	// Message explains the state, such as why a check is waiting.
+	Message    string    `json:"message,omitempty"`
+	LastCheck  time.Time `json:"lastCheck,omitzero"`
+	LastChange time.Time `json:"lastChange,omitzero"`
+	LastError  string    `json:"lastError,omitempty"`
+	// ContentType is the media type of the last response.
+	ContentType string `json:"contentType,omitempty"`
+	// Magnitude is the size of the last detected change, whether or not it
+	// crossed the monitor's threshold.
+	Magnitude *ChangeMagnitude `json:"magnitude,omitempty"`
+}
+
+// monitorStatus guards a monitor's Status, which is updated by the monitor's
+// goroutine and read by the web server.
+type monitorStatus struct {
+	mu     sync.Mutex
+	status Status
+}
+
+func newMonitorStatus(name string) *monitorStatus {
+	return &monitorStatus{status: Status{Name: name, State: StateIdle}}
+}
+
+func (s *monitorStatus) get() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+func (s *monitorStatus) update(fn func(*Status)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&s.status)
+}
+
+func (s *monitorStatus) begin() {
+	s.update(func(st *Status) {
+		st.State = StateChecking
+		st.Message = ""
+	})
+}
+
+// waiting records that the check is held back for the given reason, or that
+// it goes on when reason is empty.
+func (s *monitorStatus) waiting(reason string) {
+	s.update(func(st *Status) {
+		st.State = StateWaiting
+		if reason == "" {
+			st.State = StateChecking
+		}
+		st.Message = reason
+	})
+}
+
+// measured records the magnitude of a detected change.
+func (s *monitorStatus) measured(c ChangeMagnitude) {
+	s.update(func(st *Status) { st.Magnitude = &c })
+}
+
+// finish records the outcome of a check. A nil err means the check
+// completed, whether or not anything changed.
+func (s *monitorStatus) finish(err error, changed bool) {
+	now := time.Now()
+	s.update(func(st *Status) {
+		st.LastCheck = now
+		st.Message = ""
+		if err != nil {
+			st.State = StateError
+			st.LastError = err.Error()
+			return
+		}
+		st.State = StateIdle
+		st.LastError = ""
+		if changed {
+			st.LastChange = now
+		}
+	})
+}
+
+// Statuses returns the status of every monitor, in configuration order. The
+// child monitors of a sitemap monitor follow it.
+func (ms *MonitorService) Statuses() []Status {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	statuses := make([]Status, 0, len(ms.monitors))
+	for i := range ms.monitors {
+		// Monitors are not copied: their goroutines write to them.
+		m := &ms.monitors[i]
+		if m.status == nil {
+			statuses = append(statuses, Status{Name: m.Name, State: StateIdle})
+			continue
+		}
+		statuses = append(statuses, m.status.get())
+		for _, child := range m.childMonitors() {
+			statuses = append(statuses, child.status.get())
+		}
+	}
+	return statuses
+}
@@ -0,0 +1,78 @@
+package monitor
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// TLSSettings adjusts how a monitor verifies and authenticates TLS
+// connections. CAFile adds a PEM bundle of trusted authorities on top of the
+// system ones, CertFile and KeyFile present a client certificate, and
+// InsecureSkipVerify accepts any server certificate, which is meant for
+// internal hosts with self-signed certificates.
+//
+// Chrome only honours InsecureSkipVerify.
+type TLSSettings struct {
+	CAFile             string `json:"caFile,omitempty"`
+	CertFile           string `json:"certFile,omitempty"`
+	KeyFile            string `json:"keyFile,omitempty"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"`
+}
+
+// key identifies the settings in the transport cache.
+func (s *TLSSettings) key() string {
+	if s == nil {
+		return ""
+	}
+	return strconv.Quote(s.CAFile) + strconv.Quote(s.CertFile) + strconv.Quote(s.KeyFile) + strconv.FormatBool(s.InsecureSkipVerify)
+}
+
+// browserCompatible reports whether Chrome can apply the settings.
+func (s *TLSSettings) browserCompatible() bool {
+	return s == nil || s.CAFile == "" && s.CertFile == "" && s.KeyFile == ""
+}
+
+// config builds the tls.Config for s, reading the files it refers to.
+func (s *TLSSettings) config() (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: s.InsecureSkipVerify}
+	roots, err := s.rootCAs()
+	if err != nil {
+		return nil, err
+	}
+	config.RootCAs = roots
+	if s.CertFile != "" || s.KeyFile != "" {
+		if s.CertFile == "" || s.KeyFile == "" {
+			return nil, errors.New("tls: certFile and keyFile must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(s.CertFile, s.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls: load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// rootCAs returns the system roots extended with CAFile, or nil to use the
+// system roots unchanged.
+func (s *TLSSettings) rootCAs() (*x509.CertPool, error) {
+	if s == nil || s.CAFile == "" {
+		return nil, nil
+	}
+	pem, err := os.ReadFile(s.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("tls: read CA bundle: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("tls: no certificates found in %s", s.CAFile)
+	}
+	return pool, nil
+}
@@ -0,0 +1,67 @@
+package monitor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Trigger modes decide which changes are notified.
+const (
+	triggerAny     = "any"
+	triggerAdded   = "added"
+	triggerRemoved = "removed"
+)
+
+// maxListedLines caps the lines listed in a notification.
+const maxListedLines = 20
+
+// triggered reports whether a change from prev to next should be notified
+// under the monitor's trigger mode.
+func (m *Monitor) triggered(prev, next string) bool {
+	added, removed := lineChanges(prev, next)
+	switch m.Trigger {
+	case triggerAdded:
+		return len(added) > 0
+	case triggerRemoved:
+		return len(removed) > 0
+	default:
+		return true
+	}
+}
+
+// changeMessage describes a change from prev to next for a notification,
+// starting with its magnitude. In the added and removed modes only the
+// relevant lines are listed.
+func (m *Monitor) changeMessage(prev, next string, magnitude ChangeMagnitude) string {
+	added, removed := lineChanges(prev, next)
+	switch m.Trigger {
+	case triggerAdded:
+		return fmt.Sprintf("%s has %d new lines: %s.\n\n---\n%s\n---", m.URL, len(added), magnitude, listLines("+ ", added))
+	case triggerRemoved:
+		return fmt.Sprintf("%s has %d removed lines: %s.\n\n---\n%s\n---", m.URL, len(removed), magnitude, listLines("- ", removed))
+	default:
+		return fmt.Sprintf("%s changed: %s.\n\n---\n(changed) %.200s\n\n(into) %.200s\n---", m.URL, magnitude, prev, next)
+	}
+}
+
+// listLines prefixes each line, listing at most maxListedLines of them.
+func listLines(prefix string, lines []string) string {
+	var b strings.Builder
+	for i, line := range lines {
+		if i == maxListedLines {
+			fmt.Fprintf(&b, "… and %d more", len(lines)-i)
+			break
+		}
+		b.WriteString(prefix + line + "\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func validateTrigger(trigger string) error {
+	switch trigger {
+	case "", triggerAny, triggerAdded, triggerRemoved:
+		return nil
+	default:
+		return fmt.Errorf("unknown trigger %q", trigger)
+	}
+}
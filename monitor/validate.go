@@ -0,0 +1,104 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Validate checks that every monitor's settings can be used, so that mistakes
+// are reported when the configuration is loaded rather than on every check.
+func (ms Monitors) Validate() error {
+	for i := range ms {
+		if err := ms[i].validate(); err != nil {
+			return fmt.Errorf("monitor %q: %w", ms[i].Name, err)
+		}
+	}
+	return nil
+}
+
+func (m *Monitor) validate() error {
+	if err := m.Selector.validate(); err != nil {
+		return err
+	}
+	// An invalid CSS selector matches nothing, which configs relied on before
+	// paths were checked, so it is only reported.
+	if err := m.Selector.checkCSS(); err != nil {
+		log.Printf("monitor: %q: selector: %v", m.Name, err)
+	}
+	if err := m.Ignore.validate(); err != nil {
+		return err
+	}
+	if err := m.Threshold.validate(); err != nil {
+		return err
+	}
+	if err := validateTrigger(m.Trigger); err != nil {
+		return err
+	}
+	if m.Conditions != nil {
+		if err := m.Conditions.validate(); err != nil {
+			return err
+		}
+	}
+	if err := m.Numeric.validate(); err != nil {
+		return err
+	}
+	if err := m.Sitemap.validate(); err != nil {
+		return err
+	}
+	return m.Items.validate(m.Selector)
+}
+
+func (s Selector) validate() error {
+	if len(s.Steps) > 0 {
+		for i, step := range s.Steps {
+			if err := step.validate(); err != nil {
+				return fmt.Errorf("selector: step %d: %w", i+1, err)
+			}
+		}
+		return nil
+	}
+	switch s.Type {
+	case "xpath":
+		if _, err := compileXPaths(s.Paths); err != nil {
+			return fmt.Errorf("selector: %w", err)
+		}
+	case "regex":
+		if _, err := s.Regex.compile(s.Paths); err != nil {
+			return fmt.Errorf("selector: %w", err)
+		}
+	case "csv":
+		for _, col := range s.Paths {
+			if strings.TrimSpace(col) == "" {
+				return errors.New("selector: empty csv column")
+			}
+		}
+	}
+	if s.Type != "regex" && s.Regex.chained() {
+		if _, err := s.Regex.compile(s.Regex.Patterns); err != nil {
+			return fmt.Errorf("selector: %w", err)
+		}
+	}
+	return nil
+}
+
+// checkCSS checks the paths of a css selector or of the css steps of a
+// pipeline.
+func (s Selector) checkCSS() error {
+	if len(s.Steps) > 0 {
+		for i, step := range s.Steps {
+			if step.Type != "css" {
+				continue
+			}
+			if err := validateCSSPaths(step.Paths); err != nil {
+				return fmt.Errorf("step %d: %w", i+1, err)
+			}
+		}
+		return nil
+	}
+	if s.Type == "css" {
+		return validateCSSPaths(s.Paths)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package monitor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
+)
+
+// getXPathSelectorContent evaluates each XPath expression against body, which
+// may be HTML or XML, and is always parsed as XML when xml is set.
+// Expressions selecting nodes yield one line per node; expressions such as
+// count() or string() yield their value.
+func getXPathSelectorContent(body io.Reader, exprs []string, xml bool) (string, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", fmt.Errorf("xpath: read body: %w", err)
+	}
+	var nav xpath.NodeNavigator
+	if xml {
+		nav, err = xmlNavigator(data)
+	} else {
+		nav, err = xpathNavigator(data)
+	}
+	if err != nil {
+		return "", err
+	}
+	compiled, err := compileXPaths(exprs)
+	if err != nil {
+		return "", err
+	}
+	results := make([]string, 0, len(compiled))
+	for _, expr := range compiled {
+		results = append(results, evaluateXPath(expr, nav.Copy()))
+	}
+	return strings.Join(results, "\n"), nil
+}
+
+func compileXPaths(paths []string) ([]*xpath.Expr, error) {
+	exprs := make([]*xpath.Expr, 0, len(paths))
+	for _, p := range paths {
+		expr, err := xpath.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("xpath: compile %q: %w", p, err)
+		}
+		exprs = append(exprs, expr)
+	}
+	return exprs, nil
+}
+
+// xpathNavigator parses data as XML when it starts with an XML declaration
+// and as HTML otherwise.
+func xpathNavigator(data []byte) (xpath.NodeNavigator, error) {
+	if isXMLDocument(data) {
+		return xmlNavigator(data)
+	}
+	doc, err := htmlquery.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("xpath: parse html: %w", err)
+	}
+	return htmlquery.CreateXPathNavigator(doc), nil
+}
+
+func xmlNavigator(data []byte) (xpath.NodeNavigator, error) {
+	doc, err := xmlquery.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("xpath: parse xml: %w", err)
+	}
+	return xmlquery.CreateXPathNavigator(doc), nil
+}
+
+func isXMLDocument(data []byte) bool {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte("<?xml"))
+}
+
+func evaluateXPath(expr *xpath.Expr, nav xpath.NodeNavigator) string {
+	result := expr.Evaluate(nav)
+	iter, ok := result.(*xpath.NodeIterator)
+	if !ok {
+		return xpathValue(result)
+	}
+	var lines []string
+	for iter.MoveNext() {
+		lines = append(lines, strings.TrimSpace(iter.Current().Value()))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// xpathValue formats the result of an expression that does not select nodes.
+func xpathValue(v any) string {
+	switch v := v.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// xpathItems evaluates expr like evaluateXPath, but returns selected elements
+// as markup so that later pipeline steps can look inside them.
+func xpathItems(expr *xpath.Expr, nav xpath.NodeNavigator) []string {
+	result := expr.Evaluate(nav)
+	iter, ok := result.(*xpath.NodeIterator)
+	if !ok {
+		return []string{xpathValue(result)}
+	}
+	var items []string
+	for iter.MoveNext() {
+		node := iter.Current()
+		if node.NodeType() != xpath.ElementNode {
+			items = append(items, node.Value())
+			continue
+		}
+		switch n := node.(type) {
+		case *htmlquery.NodeNavigator:
+			items = append(items, htmlquery.OutputHTML(n.Current(), true))
+		case *xmlquery.NodeNavigator:
+			items = append(items, n.Current().OutputXML(true))
+		default:
+			items = append(items, node.Value())
+		}
+	}
+	return items
+}
@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 type Storage struct {
@@ -39,8 +40,18 @@ func (s *Storage) WriteContent(id string, content string) {
 	}
 }
 
+// DeleteContent removes the state file for id. A missing file is not an error.
+func (s *Storage) DeleteContent(id string) error {
+	err := os.Remove(filepath.Join(s.Directory, id))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
 // Cleanup removes state files for any monitor ID not present in activeIDs.
 // Files in the storage directory that do not match an active ID are deleted.
+// Auxiliary state is stored as "<id>.<kind>" and is kept alongside its ID.
 func (s *Storage) Cleanup(activeIDs []string) error {
 	entries, err := os.ReadDir(s.Directory)
 	if err != nil {
@@ -57,7 +68,8 @@ func (s *Storage) Cleanup(activeIDs []string) error {
 		if e.IsDir() {
 			continue
 		}
-		if _, ok := active[e.Name()]; !ok {
+		id, _, _ := strings.Cut(e.Name(), ".")
+		if _, ok := active[id]; !ok {
 			path := filepath.Join(s.Directory, e.Name())
 			if err := os.Remove(path); err != nil {
 				log.Printf("storage: cleanup: %v", err)